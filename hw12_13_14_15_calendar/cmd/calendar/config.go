@@ -1,20 +1,172 @@
 package main
 
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/scheduler"
+	internalhttp "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/server/http"
+)
+
 // При желании конфигурацию можно вынести в internal/config.
 // Организация конфига в main принуждает нас сужать API компонентов, использовать
 // при их конструировании только необходимые параметры, а также уменьшает вероятность циклической зависимости.
 type Config struct {
-	Logger LoggerConf
-	// TODO
+	Logger  LoggerConf  `config:"logger"`
+	Storage StorageConf `config:"storage"`
+	HTTP    HTTPConf    `config:"http"`
+	GRPC    GRPCConf    `config:"grpc"`
+	// Reminders - встроенные планировщик и рассыльщик для запуска в одном процессе.
+	Reminders RemindersConf `config:"reminders"`
 }
 
 type LoggerConf struct {
-	Level string
-	// TODO
+	Level string `config:"level"`
+	// Format - text или json.
+	Format string `config:"format"`
+	// Output - stdout, stderr или путь к файлу.
+	Output     string `config:"output"`
+	MaxSize    int    `config:"max_size"`
+	MaxBackups int    `config:"max_backups"`
+	MaxAge     int    `config:"max_age"`
+}
+
+type HTTPConf struct {
+	Host           string   `config:"host"`
+	Port           int      `config:"port"`
+	AllowedOrigins []string `config:"allowed_origins"`
+	RateLimit      float64  `config:"rate_limit"`
+	RateBurst      int      `config:"rate_burst"`
+	TrustedProxies []string `config:"trusted_proxies"`
+	// AccessLog - stdout, stderr или путь к файлу журнала запросов.
+	AccessLog string `config:"access_log"`
+	// AccessLogFormat - combined или json.
+	AccessLogFormat string `config:"access_log_format"`
+	// ShutdownTimeout - сколько ждать завершения обрабатываемых запросов при остановке.
+	ShutdownTimeout time.Duration `config:"shutdown_timeout"`
+}
+
+type GRPCConf struct {
+	Host string `config:"host"`
+	Port int    `config:"port"`
+}
+
+// RemindersConf включает планировщик и рассыльщик внутри календаря, связанные очередью
+// в памяти. Режим предназначен для разработки, в остальных случаях запускаются
+// calendar_scheduler и calendar_sender.
+type RemindersConf struct {
+	Enabled        bool          `config:"enabled"`
+	Interval       time.Duration `config:"interval"`
+	Retention      time.Duration `config:"retention"`
+	TrashRetention time.Duration `config:"trash_retention"`
+}
+
+type StorageConf struct {
+	// Type - используемая реализация хранилища: memory или sql.
+	Type string  `config:"type"`
+	SQL  SQLConf `config:"sql"`
+}
+
+type SQLConf struct {
+	DSN string `config:"dsn"`
 }
 
-func NewConfig() Config {
-	return Config{}
+// NewConfig читает конфигурацию из файла path, дополняя её значениями по умолчанию
+// и переменными окружения CALENDAR_*.
+func NewConfig(path string) (Config, error) {
+	c := Config{
+		Logger:  LoggerConf{Level: "INFO", Format: string(logger.FormatText), Output: "stdout"},
+		Storage: StorageConf{Type: storageMemory},
+		HTTP: HTTPConf{
+			Host:            "0.0.0.0",
+			Port:            8080,
+			AccessLog:       "stdout",
+			AccessLogFormat: internalhttp.AccessLogCombined,
+			ShutdownTimeout: 10 * time.Second,
+		},
+		GRPC: GRPCConf{Host: "0.0.0.0", Port: 50051},
+		Reminders: RemindersConf{
+			Interval:       time.Minute,
+			Retention:      365 * 24 * time.Hour,
+			TrashRetention: 30 * 24 * time.Hour,
+		},
+	}
+	if err := config.Load(path, &c); err != nil {
+		return Config{}, err
+	}
+	return c, nil
 }
 
-// TODO
+func (c Config) Validate() error {
+	if _, err := logger.ParseLevel(c.Logger.Level); err != nil {
+		return fmt.Errorf("logger.level: %w", err)
+	}
+	if _, err := logger.ParseFormat(c.Logger.Format); err != nil {
+		return fmt.Errorf("logger.format: %w", err)
+	}
+	if c.HTTP.Port <= 0 || c.HTTP.Port > 65535 {
+		return fmt.Errorf("http.port %d is out of range", c.HTTP.Port)
+	}
+	if c.GRPC.Port <= 0 || c.GRPC.Port > 65535 {
+		return fmt.Errorf("grpc.port %d is out of range", c.GRPC.Port)
+	}
+	if c.HTTP.RateLimit < 0 || c.HTTP.RateBurst < 0 {
+		return errors.New("http.rate_limit and http.rate_burst must not be negative")
+	}
+	if c.HTTP.RateLimit > 0 && c.HTTP.RateBurst == 0 {
+		return errors.New("http.rate_burst is required when http.rate_limit is set")
+	}
+	switch c.HTTP.AccessLogFormat {
+	case internalhttp.AccessLogCombined, internalhttp.AccessLogJSON:
+	default:
+		return fmt.Errorf("unknown http.access_log_format %q", c.HTTP.AccessLogFormat)
+	}
+
+	if c.Reminders.Enabled &&
+		(c.Reminders.Interval <= 0 || c.Reminders.Retention < 0 || c.Reminders.TrashRetention < 0) {
+		return errors.New("reminders.interval must be positive, " +
+			"reminders.retention and reminders.trash_retention must not be negative")
+	}
+
+	switch c.Storage.Type {
+	case storageMemory:
+	case storageSQL:
+		if c.Storage.SQL.DSN == "" {
+			return errors.New("storage.sql.dsn is required for sql storage")
+		}
+	default:
+		return fmt.Errorf("unknown storage.type %q", c.Storage.Type)
+	}
+	return nil
+}
+
+// AccessLogOutputConf использует для журнала запросов те же параметры ротации, что и для основного лога.
+func (c Config) AccessLogOutputConf() logger.OutputConf {
+	conf := c.Logger.OutputConf()
+	conf.Path = c.HTTP.AccessLog
+	return conf
+}
+
+func (c HTTPConf) Settings() internalhttp.Settings {
+	return internalhttp.Settings{
+		AllowedOrigins: c.AllowedOrigins,
+		RateLimit:      c.RateLimit,
+		RateBurst:      c.RateBurst,
+	}
+}
+
+func (c RemindersConf) Settings() scheduler.Settings {
+	return scheduler.Settings{Interval: c.Interval, Retention: c.Retention, TrashRetention: c.TrashRetention}
+}
+
+func (c LoggerConf) OutputConf() logger.OutputConf {
+	return logger.OutputConf{
+		Path:       c.Output,
+		MaxSize:    c.MaxSize,
+		MaxBackups: c.MaxBackups,
+		MaxAge:     c.MaxAge,
+	}
+}
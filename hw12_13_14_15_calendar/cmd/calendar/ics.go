@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/ical"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+)
+
+const icsUsage = `usage:
+  calendar ics export -user ID -from DATE -to DATE [-out FILE]
+  calendar ics import -user ID FILE`
+
+// runICS выгружает события пользователя в файл iCalendar или загружает их из файла,
+// работая с хранилищем из конфигурации напрямую, без запущенного сервиса. Хранилище
+// в памяти для этого не подходит: оно пусто и исчезает вместе с командой.
+func runICS(ctx context.Context, config Config, args []string) error {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		return errors.New(icsUsage)
+	}
+	if config.Storage.Type == storageMemory {
+		return errors.New(`storage.type "memory" is not persistent, configure "sql" storage`)
+	}
+
+	flags := flag.NewFlagSet("ics "+args[0], flag.ContinueOnError)
+	userID := flags.String("user", "", "Owner of the events")
+	from := flags.String("from", "", "Start of the exported period, YYYY-MM-DD or RFC 3339")
+	to := flags.String("to", "", "End of the exported period (exclusive), YYYY-MM-DD or RFC 3339")
+	out := flags.String("out", "", "Output file, standard output by default")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *userID == "" {
+		return errors.New("-user is required\n" + icsUsage)
+	}
+
+	format, _ := logger.ParseFormat(config.Logger.Format)
+	logg := logger.New(config.Logger.Level, logger.WithFormat(format), logger.WithOutput(os.Stderr))
+
+	storage, err := NewStorage(ctx, config.Storage)
+	if err != nil {
+		return err
+	}
+	defer storage.Close(ctx)
+
+	calendar := app.New(logg, storage)
+	ctx = reqctx.WithUserID(ctx, *userID)
+
+	if args[0] == "export" {
+		return exportICS(ctx, calendar, *from, *to, *out)
+	}
+	if flags.NArg() != 1 {
+		return errors.New(icsUsage)
+	}
+	return importICS(ctx, calendar, flags.Arg(0))
+}
+
+func exportICS(ctx context.Context, calendar *app.App, fromArg, toArg, out string) error {
+	from, err := parseDate(fromArg)
+	if err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	to, err := parseDate(toArg)
+	if err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+
+	events, err := calendar.ExportEvents(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return ical.Encode(w, events, time.Now())
+}
+
+func importICS(ctx context.Context, calendar *app.App, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	events, err := ical.Decode(f)
+	if err != nil {
+		return err
+	}
+	results, err := calendar.ImportEvents(ctx, events)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Printf("%s: %v\n", result.UID, result.Err)
+		}
+	}
+	fmt.Printf("imported %d of %d events\n", len(results)-failed, len(results))
+	return nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
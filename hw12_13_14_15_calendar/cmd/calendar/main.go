@@ -3,15 +3,18 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
+	_ "time/tzdata" // часовые пояса событий не зависят от tzdata в образе
 
 	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/app"
 	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/server/caldav"
+	internalgrpc "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/server/grpc"
 	internalhttp "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/server/http"
-	memorystorage "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage/memory"
 )
 
 var configFile string
@@ -28,34 +31,120 @@ func main() {
 		return
 	}
 
-	config := NewConfig()
-	logg := logger.New(config.Logger.Level)
+	config, err := NewConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to load config: "+err.Error())
+		os.Exit(1)
+	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(context.Background(), config, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "migrate: "+err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "ics" {
+		if err := runICS(context.Background(), config, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "ics: "+err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	format, _ := logger.ParseFormat(config.Logger.Format)
+	logOutput := logger.NewOutput(config.Logger.OutputConf())
+	defer logOutput.Close()
+	logg := logger.New(config.Logger.Level, logger.WithFormat(format), logger.WithOutput(logOutput))
+
+	storage, err := NewStorage(context.Background(), config.Storage)
+	if err != nil {
+		logg.Error("failed to init storage: " + err.Error())
+		os.Exit(1)
+	}
+	defer storage.Close(context.Background())
 
-	storage := memorystorage.New()
 	calendar := app.New(logg, storage)
 
-	server := internalhttp.NewServer(logg, calendar)
+	accessLog := logger.NewOutput(config.AccessLogOutputConf())
+	defer accessLog.Close()
+
+	grpcServer := internalgrpc.NewServer(logg, calendar, config.GRPC.Host, config.GRPC.Port)
+
+	server, err := internalhttp.NewServer(logg, grpcServer, internalhttp.Config{
+		Host:            config.HTTP.Host,
+		Port:            config.HTTP.Port,
+		TrustedProxies:  config.HTTP.TrustedProxies,
+		AccessLog:       accessLog,
+		AccessLogFormat: config.HTTP.AccessLogFormat,
+		Settings:        config.HTTP.Settings(),
+		CalDAV:          caldav.NewHandler(logg, calendar),
+	})
+	if err != nil {
+		logg.Error("failed to init http server: " + err.Error())
+		os.Exit(1) //nolint:gocritic
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(),
-		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	var sched *scheduler.Scheduler
+	if config.Reminders.Enabled {
+		sched = runReminders(ctx, logg, storage, config.Reminders)
+	}
+
+	reload := &reloader{path: configFile, config: config, logger: logg, server: server, scheduler: sched}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				logg.Info("reloading config...")
+				reload.Reload()
+			}
+		}
+	}()
+
+	// stopped закрывается, когда серверы дождались завершения обрабатываемых запросов:
+	// до этого main не возвращается и хранилище не закрывается.
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-ctx.Done()
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		ctx, cancel := context.WithTimeout(context.Background(), config.HTTP.ShutdownTimeout)
 		defer cancel()
 
 		if err := server.Stop(ctx); err != nil {
 			logg.Error("failed to stop http server: " + err.Error())
 		}
+		if err := grpcServer.Stop(ctx); err != nil {
+			logg.Error("failed to stop grpc server: " + err.Error())
+		}
 	}()
 
 	logg.Info("calendar is running...")
 
+	go func() {
+		if err := grpcServer.Start(ctx); err != nil {
+			logg.Error("failed to start grpc server: " + err.Error())
+			cancel()
+		}
+	}()
+
 	if err := server.Start(ctx); err != nil {
 		logg.Error("failed to start http server: " + err.Error())
 		cancel()
+		<-stopped
 		os.Exit(1) //nolint:gocritic
 	}
+	cancel()
+	<-stopped
 }
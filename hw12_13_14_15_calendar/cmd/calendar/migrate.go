@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	sqlstorage "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage/sql"
+)
+
+var migrateCommands = map[string]bool{
+	"up":     true,
+	"down":   true,
+	"status": true,
+}
+
+func runMigrate(ctx context.Context, config Config, args []string) error {
+	if len(args) == 0 || !migrateCommands[args[0]] {
+		return errors.New("usage: calendar migrate up|down|status")
+	}
+
+	storage := sqlstorage.New(config.Storage.SQL.DSN)
+	if err := storage.Connect(ctx); err != nil {
+		return err
+	}
+	defer storage.Close(ctx)
+
+	return storage.Migrate(args[0])
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/scheduler"
+	internalhttp "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/server/http"
+)
+
+// reloader перечитывает файл конфигурации по SIGHUP и применяет настройки,
+// которые можно менять без перезапуска сервиса.
+type reloader struct {
+	path   string
+	config Config
+	logger *logger.Logger
+	server *internalhttp.Server
+	// scheduler - встроенный планировщик, nil, если напоминания не включены.
+	scheduler *scheduler.Scheduler
+}
+
+func (r *reloader) Reload() {
+	next, err := NewConfig(r.path)
+	if err != nil {
+		r.logger.Error("failed to reload config, keeping current one: " + err.Error())
+		return
+	}
+
+	if next.Logger.Level != r.config.Logger.Level {
+		if err := r.logger.SetLevel(next.Logger.Level); err != nil {
+			r.logger.Error("failed to apply logger.level: " + err.Error())
+		} else {
+			r.config.Logger.Level = next.Logger.Level
+			r.logger.Info("applied logger.level = " + next.Logger.Level)
+		}
+	}
+
+	if !reflect.DeepEqual(next.HTTP.Settings(), r.config.HTTP.Settings()) {
+		r.server.SetSettings(next.HTTP.Settings())
+		r.config.HTTP.AllowedOrigins = next.HTTP.AllowedOrigins
+		r.config.HTTP.RateLimit = next.HTTP.RateLimit
+		r.config.HTTP.RateBurst = next.HTTP.RateBurst
+		r.logger.Info("applied http cors and rate limit settings")
+	}
+
+	if next.Reminders.Enabled != r.config.Reminders.Enabled {
+		r.logger.Warn("reminders.enabled changed, restart required: ignored")
+	} else if r.scheduler != nil && next.Reminders != r.config.Reminders {
+		r.scheduler.SetSettings(next.Reminders.Settings())
+		r.config.Reminders = next.Reminders
+		r.logger.Info("applied reminders.interval = " + next.Reminders.Interval.String() +
+			", reminders.retention = " + next.Reminders.Retention.String() +
+			", reminders.trash_retention = " + next.Reminders.TrashRetention.String())
+	}
+
+	if next.HTTP.Host != r.config.HTTP.Host || next.HTTP.Port != r.config.HTTP.Port {
+		r.logger.Warn("http.host and http.port changed, restart required: ignored")
+	}
+	if next.GRPC != r.config.GRPC {
+		r.logger.Warn("grpc settings changed, restart required: ignored")
+	}
+	if !reflect.DeepEqual(next.HTTP.TrustedProxies, r.config.HTTP.TrustedProxies) ||
+		next.HTTP.AccessLog != r.config.HTTP.AccessLog ||
+		next.HTTP.AccessLogFormat != r.config.HTTP.AccessLogFormat ||
+		next.HTTP.ShutdownTimeout != r.config.HTTP.ShutdownTimeout {
+		r.logger.Warn("http access log and shutdown settings changed, restart required: ignored")
+	}
+	if next.Logger.OutputConf() != r.config.Logger.OutputConf() || next.Logger.Format != r.config.Logger.Format {
+		r.logger.Warn("logger output and format changed, restart required: ignored")
+	}
+	if !reflect.DeepEqual(next.Storage, r.config.Storage) {
+		r.logger.Warn("storage settings changed, restart required: ignored")
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/queue"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/sender"
+)
+
+// runReminders запускает планировщик и рассыльщик, связанные очередью в памяти,
+// и останавливает их при отмене ctx.
+func runReminders(ctx context.Context, logg *logger.Logger, storage Storage, conf RemindersConf) *scheduler.Scheduler {
+	q := queue.NewMemory(logg, queue.MemoryConfig{})
+	sched := scheduler.New(logg, storage, q, conf.Settings())
+	send := sender.New(logg, sender.NewWriterNotifier(os.Stdout), nil)
+
+	go sched.Run(ctx)
+	go func() {
+		if err := q.Consume(ctx, send.Handle); err != nil {
+			logg.Error("failed to consume notifications: " + err.Error())
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		q.Close()
+	}()
+
+	logg.Info("reminders are running in-process")
+	return sched
+}
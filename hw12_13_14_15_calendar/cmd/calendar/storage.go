@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/scheduler"
+	memorystorage "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage/memory"
+	sqlstorage "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage/sql"
+)
+
+const (
+	storageMemory = "memory"
+	storageSQL    = "sql"
+)
+
+// Storage - хранилище, которое может потребовать подключения и освобождения ресурсов.
+type Storage interface {
+	app.Storage
+	scheduler.Storage
+	Connect(ctx context.Context) error
+	Close(ctx context.Context) error
+}
+
+// NewStorage создаёт и подключает реализацию хранилища, указанную в конфигурации.
+func NewStorage(ctx context.Context, conf StorageConf) (Storage, error) {
+	var storage Storage
+	switch conf.Type {
+	case storageMemory:
+		storage = memorystorage.New()
+	case storageSQL:
+		storage = sqlstorage.New(conf.SQL.DSN)
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", conf.Type)
+	}
+
+	if err := storage.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("connect to %s storage: %w", conf.Type, err)
+	}
+	return storage, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	_ "time/tzdata" // часовые пояса событий не зависят от tzdata в образе
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/queue"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/scheduler"
+	sqlstorage "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage/sql"
+)
+
+var configFile string
+
+func init() {
+	flag.StringVar(&configFile, "config", "/etc/calendar/scheduler_config.toml", "Path to configuration file")
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.Arg(0) == "version" {
+		printVersion()
+		return
+	}
+
+	config, err := NewConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to load config: "+err.Error())
+		os.Exit(1)
+	}
+
+	format, _ := logger.ParseFormat(config.Logger.Format)
+	logOutput := logger.NewOutput(config.Logger.OutputConf())
+	defer logOutput.Close()
+	logg := logger.New(config.Logger.Level, logger.WithFormat(format), logger.WithOutput(logOutput))
+
+	ctx, cancel := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	storage := sqlstorage.New(config.Storage.SQL.DSN)
+	if err := storage.Connect(ctx); err != nil {
+		logg.Error("failed to init storage: " + err.Error())
+		os.Exit(1) //nolint:gocritic
+	}
+	defer storage.Close(context.Background())
+
+	publisher := queue.NewAMQPPublisher(logg, config.Queue.Config())
+	if err := publisher.Connect(ctx); err != nil {
+		logg.Error("failed to connect to queue: " + err.Error())
+		os.Exit(1)
+	}
+	defer publisher.Close()
+
+	sched := scheduler.New(logg, storage, publisher, config.Scheduler.Settings())
+
+	reload := &reloader{path: configFile, config: config, logger: logg, scheduler: sched}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				logg.Info("reloading config...")
+				reload.Reload()
+			}
+		}
+	}()
+
+	logg.Info("scheduler is running...")
+	sched.Run(ctx)
+	logg.Info("scheduler is stopped")
+}
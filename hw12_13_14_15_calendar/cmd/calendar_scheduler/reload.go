@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/scheduler"
+)
+
+// reloader перечитывает файл конфигурации по SIGHUP и применяет настройки,
+// которые можно менять без перезапуска планировщика.
+type reloader struct {
+	path      string
+	config    Config
+	logger    *logger.Logger
+	scheduler *scheduler.Scheduler
+}
+
+func (r *reloader) Reload() {
+	next, err := NewConfig(r.path)
+	if err != nil {
+		r.logger.Error("failed to reload config, keeping current one: " + err.Error())
+		return
+	}
+
+	if next.Logger.Level != r.config.Logger.Level {
+		if err := r.logger.SetLevel(next.Logger.Level); err != nil {
+			r.logger.Error("failed to apply logger.level: " + err.Error())
+		} else {
+			r.config.Logger.Level = next.Logger.Level
+			r.logger.Info("applied logger.level = " + next.Logger.Level)
+		}
+	}
+
+	if next.Scheduler != r.config.Scheduler {
+		r.scheduler.SetSettings(next.Scheduler.Settings())
+		r.config.Scheduler = next.Scheduler
+		r.logger.Info("applied scheduler.interval = " + next.Scheduler.Interval.String() +
+			", scheduler.retention = " + next.Scheduler.Retention.String() +
+			", scheduler.trash_retention = " + next.Scheduler.TrashRetention.String())
+	}
+
+	if next.Logger.OutputConf() != r.config.Logger.OutputConf() || next.Logger.Format != r.config.Logger.Format {
+		r.logger.Warn("logger output and format changed, restart required: ignored")
+	}
+	if !reflect.DeepEqual(next.Storage, r.config.Storage) {
+		r.logger.Warn("storage settings changed, restart required: ignored")
+	}
+	if next.Queue != r.config.Queue {
+		r.logger.Warn("queue settings changed, restart required: ignored")
+	}
+}
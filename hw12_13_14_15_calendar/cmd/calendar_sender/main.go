@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/queue"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/sender"
+)
+
+var configFile string
+
+func init() {
+	flag.StringVar(&configFile, "config", "/etc/calendar/sender_config.toml", "Path to configuration file")
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.Arg(0) == "version" {
+		printVersion()
+		return
+	}
+
+	config, err := NewConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to load config: "+err.Error())
+		os.Exit(1)
+	}
+
+	format, _ := logger.ParseFormat(config.Logger.Format)
+	logOutput := logger.NewOutput(config.Logger.OutputConf())
+	defer logOutput.Close()
+	logg := logger.New(config.Logger.Level, logger.WithFormat(format), logger.WithOutput(logOutput))
+
+	ctx, cancel := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	consumer := queue.NewAMQPConsumer(logg, config.Queue.Config())
+	if err := consumer.Connect(ctx); err != nil {
+		logg.Error("failed to connect to queue: " + err.Error())
+		os.Exit(1) //nolint:gocritic
+	}
+	defer consumer.Close()
+
+	var status sender.Publisher
+	if config.Queue.StatusQueue != "" {
+		publisher := queue.NewAMQPPublisher(logg, config.Queue.StatusConfig())
+		if err := publisher.Connect(ctx); err != nil {
+			logg.Error("failed to connect to status queue: " + err.Error())
+			os.Exit(1)
+		}
+		defer publisher.Close()
+		status = publisher
+	}
+
+	var notifier sender.Notifier
+	switch config.Sender.Notifier {
+	case notifierLog:
+		notifier = sender.NewLogNotifier(logg)
+	default:
+		notifier = sender.NewWriterNotifier(os.Stdout)
+	}
+
+	reload := &reloader{path: configFile, config: config, logger: logg}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				logg.Info("reloading config...")
+				reload.Reload()
+			}
+		}
+	}()
+
+	logg.Info("sender is running...")
+	if err := consumer.Consume(ctx, sender.New(logg, notifier, status).Handle); err != nil {
+		logg.Error("failed to consume notifications: " + err.Error())
+		cancel()
+		os.Exit(1)
+	}
+	logg.Info("sender is stopped")
+}
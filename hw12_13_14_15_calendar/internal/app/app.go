@@ -2,25 +2,560 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+// inviteAttempts ограничивает число попыток пригласить участников, если событие
+// одновременно изменили, например участник ответил на приглашение.
+const inviteAttempts = 3
+
+const (
+	// DefaultPageSize - размер страницы списка событий, если клиент его не задал.
+	DefaultPageSize = 100
+	// MaxPageSize - наибольший размер страницы, больший запрошенный размер уменьшается до него.
+	MaxPageSize = 1000
 )
 
-type App struct { // TODO
+var (
+	// ErrUserRequired возвращается, если в контексте операции нет ID пользователя.
+	ErrUserRequired = errors.New("user id required")
+	// ErrInvalidPeriod возвращается, если конец периода не позже его начала.
+	ErrInvalidPeriod = errors.New("invalid period")
+	// ErrInvalidTimeZone возвращается, если часовой пояс списка событий неизвестен.
+	ErrInvalidTimeZone = errors.New("invalid time zone")
+	// ErrForbidden возвращается участнику, который пытается изменить или удалить
+	// чужое событие: это может только владелец.
+	ErrForbidden = errors.New("only the event owner can change it")
+	// ErrInvalidPage возвращается при отрицательном размере страницы или неверном курсоре.
+	ErrInvalidPage = errors.New("invalid page")
+)
+
+// App работает только с событиями пользователя, ID которого передан
+// в контексте (см. reqctx.WithUserID), и событиями, на которые он приглашён.
+// Остальные события для него не существуют.
+type App struct {
+	logger  Logger
+	storage Storage
+}
+
+type Logger interface {
+	DebugContext(ctx context.Context, msg string, keyvals ...interface{})
+	InfoContext(ctx context.Context, msg string, keyvals ...interface{})
+	WarnContext(ctx context.Context, msg string, keyvals ...interface{})
+	ErrorContext(ctx context.Context, msg string, keyvals ...interface{})
 }
 
-type Logger interface { // TODO
+type Storage interface {
+	Create(ctx context.Context, event storage.Event) error
+	Update(ctx context.Context, id string, event storage.Event) error
+	Delete(ctx context.Context, id string, version int64) error
+	Get(ctx context.Context, id string) (storage.Event, error)
+	GetByUID(ctx context.Context, userID, uid string) (storage.Event, error)
+	ListDay(ctx context.Context, userID string, date time.Time, opts storage.ListOptions) ([]storage.Event, error)
+	ListWeek(ctx context.Context, userID string, start time.Time, opts storage.ListOptions) ([]storage.Event, error)
+	ListMonth(ctx context.Context, userID string, start time.Time, opts storage.ListOptions) ([]storage.Event, error)
+	ListRange(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error)
+	ListOwned(ctx context.Context, userID string) ([]storage.Event, error)
+	SetAttendeeStatus(ctx context.Context, id, userID string, status storage.AttendeeStatus) error
+	Restore(ctx context.Context, id, userID string) error
+	ListDeleted(ctx context.Context, userID string) ([]storage.Event, error)
 }
 
-type Storage interface { // TODO
+// ListQuery - фильтр и страница списка событий за период.
+type ListQuery struct {
+	storage.ListFilter
+	// PageSize - число событий на странице: 0 - DefaultPageSize, больше MaxPageSize - MaxPageSize.
+	PageSize int
+	// Cursor - NextCursor предыдущей страницы, пустая строка - первая страница.
+	Cursor string
+}
+
+// EventPage - страница списка событий, упорядоченного по времени начала и ID.
+type EventPage struct {
+	Events []storage.Event
+	// NextCursor запрашивает следующую страницу, пустая строка - страница последняя.
+	NextCursor string
 }
 
 func New(logger Logger, storage Storage) *App {
-	return &App{}
+	return &App{
+		logger:  logger,
+		storage: storage,
+	}
+}
+
+// CreateEvent сохраняет событие текущего пользователя, назначая ему ID, если он не задан,
+// и UID, равный ID, если не задан UID. Участники события получают приглашения со статусом pending.
+func (a *App) CreateEvent(ctx context.Context, event storage.Event) (storage.Event, error) {
+	userID, err := a.userID(ctx)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.UID == "" {
+		event.UID = event.ID
+	}
+	event.UserID = userID
+	event.Attendees = invite(event.Attendees)
+	event.Version = 1
+	event = normalize(event)
+	if err := a.storage.Create(ctx, event); err != nil {
+		a.logError(ctx, "failed to create event", err, "event_id", event.ID)
+		return storage.Event{}, err
+	}
+	a.logger.InfoContext(ctx, "event created", "event_id", event.ID)
+	return event, nil
 }
 
-func (a *App) CreateEvent(ctx context.Context, id, title string) error {
-	// TODO
+// UpdateEvent заменяет событие текущего пользователя и возвращает сохранённую версию.
+// Новые участники получают приглашения, ответы прежних участников сохраняются.
+// event.Version - версия, которую прочитал клиент: если событие с тех пор изменили,
+// возвращается storage.ErrVersionConflict. Нулевая версия заменяется текущей.
+func (a *App) UpdateEvent(ctx context.Context, id string, event storage.Event) (storage.Event, error) {
+	old, err := a.ownEvent(ctx, id)
+	if err != nil {
+		a.logError(ctx, "failed to update event", err, "event_id", id)
+		return storage.Event{}, err
+	}
+	event.ID = id
+	event.UID = old.UID
+	event.UserID = reqctx.UserID(ctx)
+	event.Attendees = invite(event.Attendees)
+	event.Attendees = event.KeepStatuses(old)
+	if event.Version == 0 {
+		event.Version = old.Version
+	}
+	event = normalize(event)
+	if err := a.storage.Update(ctx, id, event); err != nil {
+		a.logError(ctx, "failed to update event", err, "event_id", id)
+		return storage.Event{}, err
+	}
+	event.Version++
+	a.logger.InfoContext(ctx, "event updated", "event_id", id)
+	return event, nil
+}
+
+// DeleteEvent переносит в корзину событие текущего пользователя версии version,
+// как в UpdateEvent. Из корзины событие можно восстановить (RestoreEvent), пока
+// планировщик не удалит его окончательно.
+func (a *App) DeleteEvent(ctx context.Context, id string, version int64) error {
+	old, err := a.ownEvent(ctx, id)
+	if err != nil {
+		a.logError(ctx, "failed to delete event", err, "event_id", id)
+		return err
+	}
+	if version == 0 {
+		version = old.Version
+	}
+	if err := a.storage.Delete(ctx, id, version); err != nil {
+		a.logError(ctx, "failed to delete event", err, "event_id", id)
+		return err
+	}
+	a.logger.InfoContext(ctx, "event deleted", "event_id", id)
 	return nil
-	// return a.storage.CreateEvent(storage.Event{ID: id, Title: title})
 }
 
-// TODO
+// ListTrash возвращает удалённые события текущего пользователя, начиная с удалённых последними.
+func (a *App) ListTrash(ctx context.Context) ([]storage.Event, error) {
+	userID, err := a.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	events, err := a.storage.ListDeleted(ctx, userID)
+	if err != nil {
+		a.logError(ctx, "failed to list trash", err)
+		return nil, err
+	}
+	a.logger.DebugContext(ctx, "trash listed", "count", len(events))
+	return events, nil
+}
+
+// RestoreEvent возвращает событие текущего пользователя из корзины. Если его время
+// за это время заняли, возвращается storage.ErrDateBusy.
+func (a *App) RestoreEvent(ctx context.Context, id string) (storage.Event, error) {
+	userID, err := a.userID(ctx)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if err := a.storage.Restore(ctx, id, userID); err != nil {
+		a.logError(ctx, "failed to restore event", err, "event_id", id)
+		return storage.Event{}, err
+	}
+	event, err := a.storage.Get(ctx, id)
+	if err != nil {
+		a.logError(ctx, "failed to restore event", err, "event_id", id)
+		return storage.Event{}, err
+	}
+	a.logger.InfoContext(ctx, "event restored", "event_id", id)
+	return event, nil
+}
+
+// InviteAttendees приглашает пользователей userIDs на событие текущего пользователя
+// и возвращает событие с обновлённым списком участников. Уже приглашённые
+// пользователи пропускаются, их ответы не меняются.
+func (a *App) InviteAttendees(ctx context.Context, id string, userIDs []string) (storage.Event, error) {
+	var (
+		event storage.Event
+		err   = fmt.Errorf("%w: no attendees to invite", storage.ErrInvalidEvent)
+	)
+	for attempt := 0; attempt < inviteAttempts && len(userIDs) > 0; attempt++ {
+		event, err = a.addAttendees(ctx, id, userIDs)
+		if !errors.Is(err, storage.ErrVersionConflict) {
+			break
+		}
+	}
+	if err != nil {
+		a.logError(ctx, "failed to invite attendees", err, "event_id", id)
+		return storage.Event{}, err
+	}
+	a.logger.InfoContext(ctx, "attendees invited", "event_id", id, "count", len(userIDs))
+	return event, nil
+}
+
+// addAttendees добавляет приглашения к прочитанной версии события и сохраняет его,
+// только если эта версия не изменилась.
+func (a *App) addAttendees(ctx context.Context, id string, userIDs []string) (storage.Event, error) {
+	event, err := a.ownEvent(ctx, id)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	attendees := append([]storage.Attendee(nil), event.Attendees...)
+	for _, userID := range uniqueStrings(userIDs) {
+		if _, ok := event.Attendee(userID); !ok {
+			attendees = append(attendees, storage.Attendee{UserID: userID, Status: storage.StatusPending})
+		}
+	}
+	event.Attendees = attendees
+	if err := a.storage.Update(ctx, id, event); err != nil {
+		return storage.Event{}, err
+	}
+	event.Version++
+	return event, nil
+}
+
+// RespondToInvitation сохраняет ответ текущего пользователя на приглашение:
+// accepted, declined или tentative.
+func (a *App) RespondToInvitation(
+	ctx context.Context, id string, status storage.AttendeeStatus,
+) (storage.Event, error) {
+	userID, err := a.userID(ctx)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if status == storage.StatusPending || !status.Valid() {
+		err := fmt.Errorf("%w: invalid response %q", storage.ErrInvalidEvent, status)
+		a.logError(ctx, "failed to respond to invitation", err, "event_id", id)
+		return storage.Event{}, err
+	}
+	if err := a.storage.SetAttendeeStatus(ctx, id, userID, status); err != nil {
+		a.logError(ctx, "failed to respond to invitation", err, "event_id", id)
+		return storage.Event{}, err
+	}
+	event, err := a.storage.Get(ctx, id)
+	if err != nil {
+		a.logError(ctx, "failed to respond to invitation", err, "event_id", id)
+		return storage.Event{}, err
+	}
+	a.logger.InfoContext(ctx, "invitation answered", "event_id", id, "status", status)
+	return event, nil
+}
+
+// GetEvent возвращает событие текущего пользователя или событие, на которое он приглашён.
+func (a *App) GetEvent(ctx context.Context, id string) (storage.Event, error) {
+	event, err := a.visibleEvent(ctx, id)
+	if err != nil {
+		a.logError(ctx, "failed to get event", err, "event_id", id)
+	}
+	return event, err
+}
+
+// GetEventByUID возвращает событие текущего пользователя с UID uid. События, на которые
+// пользователь приглашён, не ищутся: UID уникален только среди событий владельца.
+func (a *App) GetEventByUID(ctx context.Context, uid string) (storage.Event, error) {
+	userID, err := a.userID(ctx)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	event, err := a.storage.GetByUID(ctx, userID, uid)
+	if err != nil {
+		a.logError(ctx, "failed to get event by uid", err, "uid", uid)
+	}
+	return event, err
+}
+
+// ListDayEvents возвращает страницу событий суток, в которые попадает date в часовом
+// поясе zone. Пустой zone заменяется поясом пользователя из контекста
+// (см. reqctx.WithTimeZone), а если его нет - UTC.
+func (a *App) ListDayEvents(ctx context.Context, date time.Time, zone string, query ListQuery) (EventPage, error) {
+	return a.list(ctx, "day", date, zone, query, a.storage.ListDay)
+}
+
+// ListWeekEvents возвращает страницу событий недели, начинающейся в день start; пояс
+// выбирается так же, как в ListDayEvents.
+func (a *App) ListWeekEvents(ctx context.Context, start time.Time, zone string, query ListQuery) (EventPage, error) {
+	return a.list(ctx, "week", start, zone, query, a.storage.ListWeek)
+}
+
+// ListMonthEvents возвращает страницу событий месяца, начинающегося в день start; пояс
+// выбирается так же, как в ListDayEvents.
+func (a *App) ListMonthEvents(ctx context.Context, start time.Time, zone string, query ListQuery) (EventPage, error) {
+	return a.list(ctx, "month", start, zone, query, a.storage.ListMonth)
+}
+
+// ExportEvents возвращает события текущего пользователя, пересекающиеся с [from, to).
+// Серия повторений возвращается один раз - целиком, с правилом повторения.
+// События, на которые пользователь только приглашён, не экспортируются.
+func (a *App) ExportEvents(ctx context.Context, from, to time.Time) ([]storage.Event, error) {
+	userID, err := a.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !to.After(from) {
+		return nil, ErrInvalidPeriod
+	}
+	events, err := a.storage.ListRange(ctx, userID, from, to)
+	if err != nil {
+		a.logError(ctx, "failed to export events", err, "from", from, "to", to)
+		return nil, err
+	}
+
+	seen := make(map[string]struct{}, len(events))
+	result := make([]storage.Event, 0, len(events))
+	for _, event := range events {
+		if _, ok := seen[event.ID]; ok || event.UserID != userID {
+			continue
+		}
+		seen[event.ID] = struct{}{}
+		if event.Recurring() {
+			// Повторение несёт время конкретного вхождения, а экспортируется серия.
+			series, err := a.storage.Get(ctx, event.ID)
+			if errors.Is(err, storage.ErrNotFound) {
+				continue // серию удалили во время экспорта
+			}
+			if err != nil {
+				a.logError(ctx, "failed to export events", err, "event_id", event.ID)
+				return nil, err
+			}
+			event = series
+		}
+		result = append(result, event)
+	}
+	a.logger.InfoContext(ctx, "events exported", "from", from, "to", to, "count", len(result))
+	return result, nil
+}
+
+// ListOwnEvents возвращает все неудалённые события текущего пользователя, как ExportEvents,
+// но без периода: серии повторений не разворачиваются, поэтому выборка не зависит от их длины.
+func (a *App) ListOwnEvents(ctx context.Context) ([]storage.Event, error) {
+	userID, err := a.userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	events, err := a.storage.ListOwned(ctx, userID)
+	if err != nil {
+		a.logError(ctx, "failed to list own events", err)
+		return nil, err
+	}
+	return events, nil
+}
+
+// ImportResult - итог импорта одного события: UID из файла и сохранённое событие
+// либо бизнес-ошибка хранилища, например storage.ErrDateBusy.
+type ImportResult struct {
+	UID   string
+	Event storage.Event
+	Err   error
+}
+
+// ImportEvents сохраняет события текущего пользователя по одному. События получают
+// новые ID, а UID из файла сохраняется: если у пользователя уже есть событие с этим UID,
+// например файл импортируют повторно, результат события - storage.ErrEventExists.
+// Конфликты и ошибки валидации отдельных событий попадают в их результаты и не прерывают
+// импорт; прочие ошибки хранилища прерывают его.
+func (a *App) ImportEvents(ctx context.Context, events []storage.Event) ([]ImportResult, error) {
+	if _, err := a.userID(ctx); err != nil {
+		return nil, err
+	}
+
+	results := make([]ImportResult, 0, len(events))
+	failed := 0
+	for _, event := range events {
+		event.ID = ""
+		created, err := a.CreateEvent(ctx, event)
+		if err != nil && !isBusinessError(err) {
+			return nil, err
+		}
+		if err != nil {
+			failed++
+		}
+		results = append(results, ImportResult{UID: event.UID, Event: created, Err: err})
+	}
+	a.logger.InfoContext(ctx, "events imported", "count", len(results)-failed, "failed", failed)
+	return results, nil
+}
+
+type listFunc func(
+	ctx context.Context, userID string, date time.Time, opts storage.ListOptions,
+) ([]storage.Event, error)
+
+func (a *App) list(
+	ctx context.Context, period string, date time.Time, zone string, query ListQuery, fn listFunc,
+) (EventPage, error) {
+	userID, err := a.userID(ctx)
+	if err != nil {
+		return EventPage{}, err
+	}
+	if zone == "" {
+		zone = reqctx.TimeZone(ctx)
+	}
+	loc, err := storage.LoadLocation(zone)
+	if err != nil {
+		a.logger.WarnContext(ctx, "unknown time zone", "zone", zone)
+		return EventPage{}, fmt.Errorf("%w: %q", ErrInvalidTimeZone, zone)
+	}
+	opts, err := listOptions(query)
+	if err != nil {
+		a.logger.WarnContext(ctx, "invalid page", "err", err)
+		return EventPage{}, err
+	}
+
+	// Хранилище считает границы периода по местному времени date. Лишнее событие
+	// показывает, что за страницей есть следующая.
+	date = date.In(loc)
+	size := opts.Limit
+	opts.Limit++
+	events, err := fn(ctx, userID, date, opts)
+	if err != nil {
+		a.logError(ctx, "failed to list events", err, "period", period, "date", date)
+		return EventPage{}, err
+	}
+	page := EventPage{Events: events}
+	if len(events) > size {
+		page.Events = events[:size]
+		page.NextCursor = storage.CursorOf(events[size-1]).String()
+	}
+	a.logger.DebugContext(ctx, "events listed", "period", period, "date", date, "count", len(page.Events))
+	return page, nil
+}
+
+// listOptions проверяет размер страницы и курсор запроса списка.
+func listOptions(query ListQuery) (storage.ListOptions, error) {
+	switch {
+	case query.PageSize < 0:
+		return storage.ListOptions{}, fmt.Errorf("%w: negative page size", ErrInvalidPage)
+	case query.PageSize == 0:
+		query.PageSize = DefaultPageSize
+	case query.PageSize > MaxPageSize:
+		query.PageSize = MaxPageSize
+	}
+	after, err := storage.ParseCursor(query.Cursor)
+	if err != nil {
+		return storage.ListOptions{}, fmt.Errorf("%w: %v", ErrInvalidPage, err)
+	}
+	return storage.ListOptions{ListFilter: query.ListFilter, After: after, Limit: query.PageSize}, nil
+}
+
+// invite сбрасывает статусы участников в pending: ответить на приглашение
+// может только сам участник (см. RespondToInvitation).
+func invite(attendees []storage.Attendee) []storage.Attendee {
+	if len(attendees) == 0 {
+		return nil
+	}
+	invited := make([]storage.Attendee, 0, len(attendees))
+	for _, attendee := range attendees {
+		invited = append(invited, storage.Attendee{UserID: attendee.UserID, Status: storage.StatusPending})
+	}
+	return invited
+}
+
+// normalize приводит моменты события к UTC. Если клиент передал время в поясе
+// с IANA-именем и не указал пояс явно, этот пояс запоминается как исходный.
+func normalize(event storage.Event) storage.Event {
+	if event.TimeZone == "" {
+		if name := event.Start.Location().String(); name != "UTC" {
+			if _, err := storage.LoadLocation(name); err == nil {
+				event.TimeZone = name
+			}
+		}
+	}
+	event.Start, event.End = event.Start.UTC(), event.End.UTC()
+	if len(event.ExDates) > 0 {
+		exdates := make([]time.Time, 0, len(event.ExDates))
+		for _, exdate := range event.ExDates {
+			exdates = append(exdates, exdate.UTC())
+		}
+		event.ExDates = exdates
+	}
+	return event
+}
+
+func (a *App) userID(ctx context.Context) (string, error) {
+	userID := reqctx.UserID(ctx)
+	if userID == "" {
+		a.logger.WarnContext(ctx, "user id is missing")
+		return "", ErrUserRequired
+	}
+	return userID, nil
+}
+
+// ownEvent возвращает событие, только если оно принадлежит текущему пользователю.
+// Участник события получает ErrForbidden, а о существовании остальных чужих событий
+// клиент не узнаёт - для него это ErrNotFound.
+func (a *App) ownEvent(ctx context.Context, id string) (storage.Event, error) {
+	event, err := a.visibleEvent(ctx, id)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if event.UserID != reqctx.UserID(ctx) {
+		return storage.Event{}, ErrForbidden
+	}
+	return event, nil
+}
+
+// visibleEvent возвращает событие, если его владелец или участник - текущий пользователь.
+func (a *App) visibleEvent(ctx context.Context, id string) (storage.Event, error) {
+	userID := reqctx.UserID(ctx)
+	if userID == "" {
+		return storage.Event{}, ErrUserRequired
+	}
+	event, err := a.storage.Get(ctx, id)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if !event.Visible(userID) {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	return event, nil
+}
+
+// logError пишет бизнес-ошибки хранилища с уровнем warn, а прочие - с уровнем error.
+func (a *App) logError(ctx context.Context, msg string, err error, keyvals ...interface{}) {
+	keyvals = append(keyvals, "err", err)
+	if isBusinessError(err) {
+		a.logger.WarnContext(ctx, msg, keyvals...)
+		return
+	}
+	a.logger.ErrorContext(ctx, msg, keyvals...)
+}
+
+func isBusinessError(err error) bool {
+	return errors.Is(err, ErrUserRequired) ||
+		errors.Is(err, ErrInvalidPeriod) ||
+		errors.Is(err, ErrInvalidTimeZone) ||
+		errors.Is(err, ErrInvalidFreeBusy) ||
+		errors.Is(err, ErrForbidden) ||
+		errors.Is(err, ErrInvalidPage) ||
+		errors.Is(err, storage.ErrDateBusy) ||
+		errors.Is(err, storage.ErrNotFound) ||
+		errors.Is(err, storage.ErrInvalidEvent) ||
+		errors.Is(err, storage.ErrEventExists) ||
+		errors.Is(err, storage.ErrVersionConflict)
+}
@@ -0,0 +1,234 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	// MaxFreeBusyPeriod ограничивает интервал поиска свободного времени.
+	MaxFreeBusyPeriod = 62 * 24 * time.Hour
+	// MaxFreeBusyUsers ограничивает число участников одного запроса.
+	MaxFreeBusyUsers = 50
+)
+
+// ErrInvalidFreeBusy возвращается, если запрос свободного времени задан некорректно.
+var ErrInvalidFreeBusy = errors.New("invalid free/busy query")
+
+// Interval - промежуток времени [Start, End).
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// WorkingHours задаёт рабочее время по местному времени пояса запроса: Start и End -
+// смещения от полуночи, Weekdays - рабочие дни недели. Нулевое значение означает
+// круглые сутки без выходных.
+type WorkingHours struct {
+	Start    time.Duration
+	End      time.Duration
+	Weekdays []time.Weekday
+}
+
+// FreeBusyQuery - запрос занятости пользователей UserIDs в интервале [From, To).
+type FreeBusyQuery struct {
+	UserIDs  []string
+	From     time.Time
+	To       time.Time
+	Duration time.Duration
+	// WorkingHours и дни недели считаются в часовом поясе TimeZone; пустой TimeZone
+	// заменяется поясом пользователя из контекста, а если его нет - UTC.
+	WorkingHours WorkingHours
+	TimeZone     string
+}
+
+// FreeBusy - объединённые занятые интервалы всех участников и свободные промежутки
+// рабочего времени, в которые помещается встреча нужной длительности.
+type FreeBusy struct {
+	Busy  []Interval
+	Slots []Interval
+}
+
+// FindFreeBusy возвращает общую занятость пользователей и свободные для всех промежутки.
+// Занятость читается теми же запросами по времени начала, которыми хранилище проверяет
+// пересечения (storage.ErrDateBusy); чужие события, приглашения на которые не приняты,
+// время не занимают. Наружу отдаются только интервалы, без содержимого
+// событий, поэтому запрос доступен любому пользователю.
+func (a *App) FindFreeBusy(ctx context.Context, query FreeBusyQuery) (FreeBusy, error) {
+	if _, err := a.userID(ctx); err != nil {
+		return FreeBusy{}, err
+	}
+	loc, err := a.validateFreeBusy(ctx, &query)
+	if err != nil {
+		a.logger.WarnContext(ctx, "invalid free/busy query", "err", err)
+		return FreeBusy{}, err
+	}
+
+	var busy []Interval
+	for _, userID := range uniqueStrings(query.UserIDs) {
+		events, err := a.storage.ListRange(ctx, userID, query.From, query.To)
+		if err != nil {
+			a.logError(ctx, "failed to find free/busy", err, "user_id", userID)
+			return FreeBusy{}, err
+		}
+		for _, event := range events {
+			if !event.Occupies(userID) {
+				continue
+			}
+			busy = append(busy, clip(Interval{Start: event.Start, End: event.End}, query.From, query.To))
+		}
+	}
+	busy = mergeIntervals(busy)
+
+	var slots []Interval
+	for _, window := range workingWindows(query.WorkingHours, query.From, query.To, loc) {
+		for _, free := range subtract(window, busy) {
+			if free.End.Sub(free.Start) >= query.Duration {
+				slots = append(slots, free)
+			}
+		}
+	}
+
+	a.logger.DebugContext(ctx, "free/busy found",
+		"users", len(query.UserIDs), "busy", len(busy), "slots", len(slots))
+	return FreeBusy{Busy: busy, Slots: slots}, nil
+}
+
+// validateFreeBusy проверяет запрос и возвращает часовой пояс рабочего времени.
+func (a *App) validateFreeBusy(ctx context.Context, query *FreeBusyQuery) (*time.Location, error) {
+	hours := query.WorkingHours
+	switch {
+	case len(query.UserIDs) == 0:
+		return nil, fmt.Errorf("%w: no users", ErrInvalidFreeBusy)
+	case len(query.UserIDs) > MaxFreeBusyUsers:
+		return nil, fmt.Errorf("%w: more than %d users", ErrInvalidFreeBusy, MaxFreeBusyUsers)
+	case !query.To.After(query.From):
+		return nil, ErrInvalidPeriod
+	case query.To.Sub(query.From) > MaxFreeBusyPeriod:
+		return nil, fmt.Errorf("%w: longer than %s", ErrInvalidPeriod, MaxFreeBusyPeriod)
+	case query.Duration <= 0:
+		return nil, fmt.Errorf("%w: duration must be positive", ErrInvalidFreeBusy)
+	case hours.Start < 0 || hours.End > 24*time.Hour || (hours.End != 0 && hours.End <= hours.Start):
+		return nil, fmt.Errorf("%w: working hours must be within a day", ErrInvalidFreeBusy)
+	}
+
+	zone := query.TimeZone
+	if zone == "" {
+		zone = reqctx.TimeZone(ctx)
+	}
+	loc, err := storage.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTimeZone, zone)
+	}
+	return loc, nil
+}
+
+// workingWindows возвращает рабочие промежутки в [from, to). Границы рабочего дня
+// считаются по местному времени loc, поэтому не сдвигаются при переходе на летнее время.
+func workingWindows(hours WorkingHours, from, to time.Time, loc *time.Location) []Interval {
+	end := hours.End
+	if end == 0 {
+		end = 24 * time.Hour
+	}
+
+	var windows []Interval
+	day, _ := storage.DayPeriod(from.In(loc))
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !workday(hours.Weekdays, day.Weekday()) {
+			continue
+		}
+		window := clip(Interval{Start: clock(day, hours.Start), End: clock(day, end)}, from, to)
+		if window.End.After(window.Start) {
+			windows = append(windows, window)
+		}
+	}
+	return windows
+}
+
+// clock возвращает момент, когда на местных часах дня day показывает offset от полуночи.
+func clock(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, int(offset/time.Second), 0, day.Location())
+}
+
+func workday(weekdays []time.Weekday, weekday time.Weekday) bool {
+	if len(weekdays) == 0 {
+		return true
+	}
+	for _, d := range weekdays {
+		if d == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeIntervals упорядочивает интервалы и объединяет пересекающиеся и смежные.
+func mergeIntervals(intervals []Interval) []Interval {
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].Start.Before(intervals[j].Start)
+	})
+	merged := make([]Interval, 0, len(intervals))
+	for _, interval := range intervals {
+		if !interval.End.After(interval.Start) {
+			continue
+		}
+		if last := len(merged) - 1; last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
+
+// subtract возвращает части window, не занятые упорядоченными интервалами busy.
+func subtract(window Interval, busy []Interval) []Interval {
+	var free []Interval
+	start := window.Start
+	for _, b := range busy {
+		if !b.End.After(start) {
+			continue
+		}
+		if !b.Start.Before(window.End) {
+			break
+		}
+		if b.Start.After(start) {
+			free = append(free, Interval{Start: start, End: b.Start})
+		}
+		start = b.End
+	}
+	if start.Before(window.End) {
+		free = append(free, Interval{Start: start, End: window.End})
+	}
+	return free
+}
+
+func clip(interval Interval, from, to time.Time) Interval {
+	if interval.Start.Before(from) {
+		interval.Start = from
+	}
+	if interval.End.After(to) {
+		interval.End = to
+	}
+	return interval
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if _, ok := seen[value]; !ok {
+			seen[value] = struct{}{}
+			result = append(result, value)
+		}
+	}
+	return result
+}
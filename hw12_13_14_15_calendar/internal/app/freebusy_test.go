@@ -0,0 +1,155 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// monday - понедельник 6 сентября 2021, полночь UTC.
+var monday = time.Date(2021, time.September, 6, 0, 0, 0, 0, time.UTC)
+
+func at(day int, hour, minute int) time.Time {
+	return monday.AddDate(0, 0, day).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+}
+
+func newTestApp(t *testing.T, events ...storage.Event) *App {
+	t.Helper()
+	s := memorystorage.New()
+	for _, event := range events {
+		require.NoError(t, s.Create(context.Background(), event))
+	}
+	return New(logger.New("error", logger.WithOutput(io.Discard)), s)
+}
+
+func TestFindFreeBusy(t *testing.T) {
+	a := newTestApp(t,
+		storage.Event{ID: "1", Title: "t", UserID: "alice", Start: at(0, 10, 0), End: at(0, 11, 0)},
+		storage.Event{ID: "2", Title: "t", UserID: "bob", Start: at(0, 10, 30), End: at(0, 12, 0)},
+		storage.Event{ID: "3", Title: "t", UserID: "bob", Start: at(0, 12, 0), End: at(0, 12, 30)},
+		storage.Event{ID: "4", Title: "t", UserID: "carol", Start: at(0, 16, 0), End: at(0, 17, 30)},
+		// Серия: ежедневно 9:00-9:30, кроме выходных.
+		storage.Event{
+			ID: "5", Title: "standup", UserID: "carol", Start: at(0, 9, 0), End: at(0, 9, 30),
+			RRule: "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR",
+		},
+		// Чужое событие не влияет на результат.
+		storage.Event{ID: "6", Title: "t", UserID: "dave", Start: at(0, 13, 0), End: at(0, 15, 0)},
+	)
+	ctx := reqctx.WithUserID(context.Background(), "alice")
+
+	result, err := a.FindFreeBusy(ctx, FreeBusyQuery{
+		UserIDs: []string{"alice", "bob", "carol", "bob"},
+		From:    at(0, 0, 0), To: at(1, 0, 0),
+		Duration:     time.Hour,
+		WorkingHours: WorkingHours{Start: 9 * time.Hour, End: 18 * time.Hour},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []Interval{
+		{Start: at(0, 9, 0), End: at(0, 9, 30)},
+		{Start: at(0, 10, 0), End: at(0, 12, 30)},
+		{Start: at(0, 16, 0), End: at(0, 17, 30)},
+	}, result.Busy)
+	// 9:30-10:00 и 17:30-18:00 короче часа.
+	require.Equal(t, []Interval{{Start: at(0, 12, 30), End: at(0, 16, 0)}}, result.Slots)
+
+	t.Run("working days", func(t *testing.T) {
+		result, err := a.FindFreeBusy(ctx, FreeBusyQuery{
+			UserIDs: []string{"carol"},
+			From:    at(4, 12, 0), To: at(7, 12, 0),
+			Duration: 30 * time.Minute,
+			WorkingHours: WorkingHours{
+				Start: 9 * time.Hour, End: 18 * time.Hour,
+				Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []Interval{
+			{Start: at(4, 12, 0), End: at(4, 18, 0)},
+			{Start: at(7, 9, 30), End: at(7, 12, 0)},
+		}, result.Slots)
+	})
+
+	t.Run("time zone", func(t *testing.T) {
+		// Рабочий день 9:00-18:00 по Москве (UTC+3) - это 6:00-15:00 UTC.
+		result, err := a.FindFreeBusy(reqctx.WithTimeZone(ctx, "Europe/Moscow"), FreeBusyQuery{
+			UserIDs: []string{"alice", "bob"},
+			From:    at(0, 0, 0), To: at(1, 0, 0),
+			Duration:     2 * time.Hour,
+			WorkingHours: WorkingHours{Start: 9 * time.Hour, End: 18 * time.Hour},
+		})
+		require.NoError(t, err)
+		require.Len(t, result.Slots, 2)
+		require.True(t, at(0, 6, 0).Equal(result.Slots[0].Start))
+		require.True(t, at(0, 10, 0).Equal(result.Slots[0].End))
+		require.True(t, at(0, 12, 30).Equal(result.Slots[1].Start))
+		require.True(t, at(0, 15, 0).Equal(result.Slots[1].End))
+	})
+
+	t.Run("attendees", func(t *testing.T) {
+		a := newTestApp(t,
+			storage.Event{
+				ID: "1", Title: "t", UserID: "alice", Start: at(0, 10, 0), End: at(0, 11, 0),
+				Attendees: []storage.Attendee{{UserID: "bob", Status: storage.StatusTentative}},
+			},
+			storage.Event{
+				ID: "2", Title: "t", UserID: "alice", Start: at(0, 12, 0), End: at(0, 13, 0),
+				Attendees: []storage.Attendee{{UserID: "bob", Status: storage.StatusDeclined}},
+			},
+		)
+		// Отклонённое приглашение время участника не занимает.
+		result, err := a.FindFreeBusy(ctx, FreeBusyQuery{
+			UserIDs: []string{"bob"}, From: at(0, 0, 0), To: at(1, 0, 0), Duration: time.Hour,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []Interval{{Start: at(0, 10, 0), End: at(0, 11, 0)}}, result.Busy)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		valid := FreeBusyQuery{UserIDs: []string{"alice"}, From: at(0, 0, 0), To: at(1, 0, 0), Duration: time.Hour}
+		tests := map[string]struct {
+			change func(q *FreeBusyQuery)
+			err    error
+		}{
+			"no users":     {func(q *FreeBusyQuery) { q.UserIDs = nil }, ErrInvalidFreeBusy},
+			"empty period": {func(q *FreeBusyQuery) { q.To = q.From }, ErrInvalidPeriod},
+			"long period":  {func(q *FreeBusyQuery) { q.To = q.From.Add(MaxFreeBusyPeriod + time.Hour) }, ErrInvalidPeriod},
+			"no duration":  {func(q *FreeBusyQuery) { q.Duration = 0 }, ErrInvalidFreeBusy},
+			"inverted hours": {
+				func(q *FreeBusyQuery) { q.WorkingHours = WorkingHours{Start: 18 * time.Hour, End: 9 * time.Hour} },
+				ErrInvalidFreeBusy,
+			},
+			"unknown zone": {func(q *FreeBusyQuery) { q.TimeZone = "Mars/Olympus" }, ErrInvalidTimeZone},
+		}
+		for name, tc := range tests {
+			query := valid
+			tc.change(&query)
+			_, err := a.FindFreeBusy(ctx, query)
+			require.ErrorIs(t, err, tc.err, name)
+		}
+
+		_, err := a.FindFreeBusy(context.Background(), valid)
+		require.ErrorIs(t, err, ErrUserRequired)
+	})
+}
+
+func TestWorkingWindowsDST(t *testing.T) {
+	berlin, err := storage.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// 28 марта 2021 в Берлине перевели часы вперёд: рабочий день остаётся 9:00-18:00.
+	from := time.Date(2021, time.March, 27, 0, 0, 0, 0, berlin)
+	hours := WorkingHours{Start: 9 * time.Hour, End: 18 * time.Hour}
+	windows := workingWindows(hours, from, from.AddDate(0, 0, 2), berlin)
+	require.Len(t, windows, 2)
+	require.True(t, time.Date(2021, time.March, 27, 8, 0, 0, 0, time.UTC).Equal(windows[0].Start))
+	require.True(t, time.Date(2021, time.March, 28, 7, 0, 0, 0, time.UTC).Equal(windows[1].Start))
+	require.True(t, time.Date(2021, time.March, 28, 16, 0, 0, 0, time.UTC).Equal(windows[1].End))
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix - префикс переменных окружения, переопределяющих значения из файла.
+const EnvPrefix = "CALENDAR"
+
+const tagName = "config"
+
+var ErrUnsupportedFormat = errors.New("unsupported config format")
+
+// Validator реализуется конфигурациями, которые умеют проверять свои поля.
+type Validator interface {
+	Validate() error
+}
+
+// Load читает файл конфигурации (toml, yaml или json по расширению) в dst,
+// поверх значений из файла применяет переменные окружения вида CALENDAR_SECTION_KEY
+// и проверяет результат, если dst реализует Validator.
+// Поля dst, отсутствующие в файле и окружении, сохраняют исходные значения.
+func Load(path string, dst interface{}) error {
+	values, err := readFile(path)
+	if err != nil {
+		return err
+	}
+
+	applyEnv(values, reflect.TypeOf(dst).Elem(), EnvPrefix)
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+		TagName:          tagName,
+		Result:           dst,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(values); err != nil {
+		return fmt.Errorf("decode config %s: %w", path, err)
+	}
+
+	if v, ok := dst.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("invalid config %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func readFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	values := make(map[string]interface{})
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// applyEnv записывает в values значения переменных окружения для всех полей типа t.
+func applyEnv(values map[string]interface{}, t reflect.Type, prefix string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get(tagName)
+		if key == "" || key == "-" {
+			continue
+		}
+		env := prefix + "_" + strings.ToUpper(key)
+
+		if field.Type.Kind() == reflect.Struct && field.Type.PkgPath() != "time" {
+			nested, ok := values[key].(map[string]interface{})
+			if !ok {
+				nested = make(map[string]interface{})
+			}
+			applyEnv(nested, field.Type, env)
+			if len(nested) > 0 {
+				values[key] = nested
+			}
+			continue
+		}
+
+		if value, ok := os.LookupEnv(env); ok {
+			values[key] = value
+		}
+	}
+}
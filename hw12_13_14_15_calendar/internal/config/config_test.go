@@ -0,0 +1,108 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Server struct {
+		Host    string        `config:"host"`
+		Port    int           `config:"port"`
+		Timeout time.Duration `config:"timeout"`
+	} `config:"server"`
+	Origins []string `config:"origins"`
+	Debug   bool     `config:"debug"`
+}
+
+func (c testConfig) Validate() error {
+	if c.Server.Host == "" {
+		return errors.New("server.host is required")
+	}
+	return nil
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	require.NoError(t, os.Setenv(key, value))
+	t.Cleanup(func() { os.Unsetenv(key) })
+}
+
+func TestLoad(t *testing.T) {
+	files := map[string]string{
+		"config.toml": `
+origins = ["a", "b"]
+[server]
+host = "localhost"
+port = 8080
+timeout = "5s"
+`,
+		"config.yaml": `
+origins: [a, b]
+server:
+  host: localhost
+  port: 8080
+  timeout: 5s
+`,
+		"config.json": `{
+	"origins": ["a", "b"],
+	"server": {"host": "localhost", "port": 8080, "timeout": "5s"}
+}`,
+	}
+
+	for name, content := range files {
+		name, content := name, content
+		t.Run(name, func(t *testing.T) {
+			var c testConfig
+			require.NoError(t, Load(writeFile(t, name, content), &c))
+			require.Equal(t, "localhost", c.Server.Host)
+			require.Equal(t, 8080, c.Server.Port)
+			require.Equal(t, 5*time.Second, c.Server.Timeout)
+			require.Equal(t, []string{"a", "b"}, c.Origins)
+		})
+	}
+
+	t.Run("env overrides", func(t *testing.T) {
+		setenv(t, "CALENDAR_SERVER_PORT", "9090")
+		setenv(t, "CALENDAR_SERVER_TIMEOUT", "1m")
+		setenv(t, "CALENDAR_ORIGINS", "x,y")
+		setenv(t, "CALENDAR_DEBUG", "true")
+
+		var c testConfig
+		require.NoError(t, Load(writeFile(t, "config.toml", "[server]\nhost = \"localhost\"\nport = 8080\n"), &c))
+		require.Equal(t, 9090, c.Server.Port)
+		require.Equal(t, time.Minute, c.Server.Timeout)
+		require.Equal(t, []string{"x", "y"}, c.Origins)
+		require.True(t, c.Debug)
+	})
+
+	t.Run("defaults are kept", func(t *testing.T) {
+		var c testConfig
+		c.Server.Port = 80
+		require.NoError(t, Load(writeFile(t, "config.toml", "[server]\nhost = \"localhost\"\n"), &c))
+		require.Equal(t, 80, c.Server.Port)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var c testConfig
+		require.ErrorIs(t, Load(writeFile(t, "config.ini", ""), &c), ErrUnsupportedFormat)
+		require.Error(t, Load(writeFile(t, "config.toml", "[server"), &c))
+		require.Error(t, Load(writeFile(t, "config.toml", "[server]\nhost = \"h\"\nunknown = 1\n"), &c))
+
+		err := Load(writeFile(t, "config.toml", "debug = true"), &testConfig{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "server.host is required")
+	})
+}
@@ -0,0 +1,436 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+var ErrInvalidCalendar = errors.New("invalid calendar")
+
+const (
+	dateFormat          = "20060102"
+	localDateTimeFormat = "20060102T150405"
+)
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+func (p property) param(name string) string {
+	return p.params[name]
+}
+
+// vevent накапливает свойства VEVENT до его окончания.
+type vevent struct {
+	props  []property
+	alarms [][]property
+}
+
+// Decode читает события VEVENT из файла iCalendar. Ошибка разбора любого события
+// означает некорректный файл; проверка самих событий остаётся хранилищу.
+//
+// UID переносится в поле UID, а ID не заполняется: его назначит приложение, потому что
+// один и тот же UID встречается в календарях разных пользователей. Изменённое повторение
+// серии (VEVENT с RECURRENCE-ID) импортируется отдельным событием, а в серию добавляется
+// соответствующий EXDATE; такие события возвращаются после всех остальных.
+func Decode(r io.Reader) ([]storage.Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: empty file", ErrInvalidCalendar)
+	}
+
+	d := &decoder{}
+	for n, line := range lines {
+		if line == "" {
+			continue
+		}
+		prop, err := parseProperty(line)
+		if err == nil {
+			err = d.add(prop)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidCalendar, n+1, err)
+		}
+	}
+	if len(d.stack) != 0 {
+		return nil, fmt.Errorf("%w: unterminated %s", ErrInvalidCalendar, d.stack[len(d.stack)-1])
+	}
+	return applyOverrides(d.masters, d.overrides), nil
+}
+
+// decoder отслеживает вложенность компонентов и собирает VEVENT верхнего уровня
+// с их VALARM; остальные компоненты, например VTIMEZONE и VTODO, пропускаются.
+type decoder struct {
+	stack     []string
+	current   *vevent
+	masters   []storage.Event
+	overrides []override
+}
+
+func (d *decoder) add(prop property) error {
+	switch prop.name {
+	case "BEGIN":
+		return d.begin(strings.ToUpper(prop.value))
+	case "END":
+		return d.end(strings.ToUpper(prop.value))
+	}
+
+	switch {
+	case d.current == nil:
+	case d.path("VCALENDAR", "VEVENT"):
+		d.current.props = append(d.current.props, prop)
+	case d.path("VCALENDAR", "VEVENT", "VALARM"):
+		last := len(d.current.alarms) - 1
+		d.current.alarms[last] = append(d.current.alarms[last], prop)
+	}
+	return nil
+}
+
+func (d *decoder) begin(name string) error {
+	if len(d.stack) == 0 && name != "VCALENDAR" {
+		return fmt.Errorf("expected VCALENDAR, got %s", name)
+	}
+	d.stack = append(d.stack, name)
+	switch {
+	case d.path("VCALENDAR", "VEVENT"):
+		d.current = &vevent{}
+	case d.path("VCALENDAR", "VEVENT", "VALARM"):
+		d.current.alarms = append(d.current.alarms, nil)
+	}
+	return nil
+}
+
+func (d *decoder) end(name string) error {
+	if len(d.stack) == 0 || d.stack[len(d.stack)-1] != name {
+		return fmt.Errorf("unexpected END:%s", name)
+	}
+	closesEvent := d.path("VCALENDAR", "VEVENT")
+	d.stack = d.stack[:len(d.stack)-1]
+	if !closesEvent {
+		return nil
+	}
+
+	event, recurrenceID, err := d.current.build()
+	if err != nil {
+		return fmt.Errorf("event %q: %w", event.UID, err)
+	}
+	if recurrenceID.IsZero() {
+		d.masters = append(d.masters, event)
+	} else {
+		d.overrides = append(d.overrides, override{event: event, recurrenceID: recurrenceID})
+	}
+	d.current = nil
+	return nil
+}
+
+// path сообщает, совпадает ли текущая вложенность компонентов с names.
+func (d *decoder) path(names ...string) bool {
+	if len(d.stack) != len(names) {
+		return false
+	}
+	for i, name := range names {
+		if d.stack[i] != name {
+			return false
+		}
+	}
+	return true
+}
+
+type override struct {
+	event        storage.Event
+	recurrenceID time.Time
+}
+
+func applyOverrides(masters []storage.Event, overrides []override) []storage.Event {
+	index := make(map[string]int, len(masters))
+	for i, master := range masters {
+		index[master.UID] = i
+	}
+	for _, o := range overrides {
+		if i, ok := index[o.event.UID]; ok && masters[i].Recurring() {
+			masters[i].ExDates = append(masters[i].ExDates, o.recurrenceID)
+		}
+		o.event.UID += "-" + formatTime(o.recurrenceID)
+		masters = append(masters, o.event)
+	}
+	return masters
+}
+
+func (v *vevent) build() (storage.Event, time.Time, error) {
+	var (
+		event        storage.Event
+		recurrenceID time.Time
+		allDay       bool
+		end          *property
+		duration     *property
+	)
+	// DTSTART разбирается первым: от него зависят EXDATE и RECURRENCE-ID в виде дат.
+	for i, prop := range v.props {
+		switch prop.name {
+		case "UID":
+			event.UID = prop.value
+		case "DTSTART":
+			var err error
+			event.Start, allDay, err = parseTime(prop, time.Time{})
+			if err != nil {
+				return event, time.Time{}, fmt.Errorf("DTSTART: %w", err)
+			}
+			if zone := event.Start.Location().String(); zone != "UTC" {
+				event.TimeZone = zone
+			}
+		case "DTEND":
+			end = &v.props[i]
+		case "DURATION":
+			duration = &v.props[i]
+		}
+	}
+	if event.Start.IsZero() {
+		return event, time.Time{}, errors.New("DTSTART is required")
+	}
+
+	for _, prop := range v.props {
+		var err error
+		switch prop.name {
+		case "SUMMARY":
+			event.Title = unescapeText(prop.value)
+		case "DESCRIPTION":
+			event.Description = unescapeText(prop.value)
+		case "RRULE":
+			event.RRule = prop.value
+		case "EXDATE":
+			var exdates []time.Time
+			exdates, err = parseExDates(prop, event.Start)
+			event.ExDates = append(event.ExDates, exdates...)
+		case "RECURRENCE-ID":
+			recurrenceID, _, err = parseTime(prop, event.Start)
+		}
+		if err != nil {
+			return event, time.Time{}, fmt.Errorf("%s: %w", prop.name, err)
+		}
+	}
+
+	switch {
+	case end != nil:
+		var err error
+		event.End, _, err = parseTime(*end, time.Time{})
+		if err != nil {
+			return event, time.Time{}, fmt.Errorf("DTEND: %w", err)
+		}
+	case duration != nil:
+		d, err := parseDuration(duration.value)
+		if err != nil {
+			return event, time.Time{}, fmt.Errorf("DURATION: %w", err)
+		}
+		event.End = event.Start.Add(d)
+	case allDay:
+		event.End = event.Start.AddDate(0, 0, 1)
+	default:
+		event.End = event.Start
+	}
+
+	for _, alarm := range v.alarms {
+		if before, ok := alarmOffset(alarm, event.Start); ok {
+			event.NotifyBefore = before
+			break
+		}
+	}
+	return event, recurrenceID, nil
+}
+
+// parseExDates разбирает список исключений EXDATE; для дат без времени
+// используется время суток начала серии.
+func parseExDates(prop property, start time.Time) ([]time.Time, error) {
+	values := strings.Split(prop.value, ",")
+	exdates := make([]time.Time, 0, len(values))
+	for _, value := range values {
+		item := prop
+		item.value = value
+		exdate, _, err := parseTime(item, start)
+		if err != nil {
+			return nil, err
+		}
+		exdates = append(exdates, exdate)
+	}
+	return exdates, nil
+}
+
+// alarmOffset возвращает, за сколько до начала события срабатывает напоминание.
+// Напоминания после начала и относительно окончания события не поддерживаются.
+func alarmOffset(props []property, start time.Time) (time.Duration, bool) {
+	for _, prop := range props {
+		if prop.name != "TRIGGER" {
+			continue
+		}
+		if strings.EqualFold(prop.param("VALUE"), "DATE-TIME") {
+			t, _, err := parseTime(prop, time.Time{})
+			if err != nil || t.After(start) {
+				return 0, false
+			}
+			return start.Sub(t), true
+		}
+		if strings.EqualFold(prop.param("RELATED"), "END") {
+			return 0, false
+		}
+		d, err := parseDuration(prop.value)
+		if err != nil || d > 0 {
+			return 0, false
+		}
+		return -d, true
+	}
+	return 0, false
+}
+
+// unfold читает строки содержимого, склеивая перенесённые: строка, начинающаяся
+// с пробела или табуляции, продолжает предыдущую.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCalendar, err)
+	}
+	return lines, nil
+}
+
+// parseProperty разбирает строку вида NAME;PARAM=VALUE;PARAM="V:A;L":value.
+// Значения параметров в кавычках могут содержать двоеточие и точку с запятой.
+func parseProperty(line string) (property, error) {
+	prop := property{params: make(map[string]string)}
+
+	end := strings.IndexAny(line, ";:")
+	if end <= 0 {
+		return prop, errors.New("malformed content line")
+	}
+	prop.name = strings.ToUpper(line[:end])
+	line = line[end:]
+
+	for strings.HasPrefix(line, ";") {
+		line = line[1:]
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			return prop, fmt.Errorf("malformed parameter of %s", prop.name)
+		}
+		name := strings.ToUpper(line[:eq])
+		line = line[eq+1:]
+
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			closing := strings.IndexByte(line[1:], '"')
+			if closing < 0 {
+				return prop, fmt.Errorf("unterminated quoted parameter of %s", prop.name)
+			}
+			value, line = line[1:closing+1], line[closing+2:]
+		} else {
+			end := strings.IndexAny(line, ";:")
+			if end < 0 {
+				return prop, fmt.Errorf("malformed parameter of %s", prop.name)
+			}
+			value, line = line[:end], line[end:]
+		}
+		prop.params[name] = value
+	}
+
+	if !strings.HasPrefix(line, ":") {
+		return prop, fmt.Errorf("missing value of %s", prop.name)
+	}
+	prop.value = line[1:]
+	return prop, nil
+}
+
+// parseTime разбирает значение DATE-TIME или DATE с учётом TZID. Время без часового
+// пояса («плавающее») считается UTC. Если задан clock, дата без времени получает
+// время суток clock, иначе - полночь.
+func parseTime(prop property, clock time.Time) (t time.Time, isDate bool, err error) {
+	loc := time.UTC
+	if tzid := strings.TrimPrefix(prop.param("TZID"), "/"); tzid != "" {
+		loc, err = time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+
+	value := prop.value
+	switch {
+	case strings.EqualFold(prop.param("VALUE"), "DATE") || len(value) == len(dateFormat):
+		t, err = time.ParseInLocation(dateFormat, value, loc)
+		if err == nil && !clock.IsZero() {
+			clock = clock.In(loc)
+			t = time.Date(t.Year(), t.Month(), t.Day(),
+				clock.Hour(), clock.Minute(), clock.Second(), clock.Nanosecond(), loc)
+		}
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse(dateTimeFormat, value)
+	default:
+		t, err = time.ParseInLocation(localDateTimeFormat, value, loc)
+	}
+	return t, false, err
+}
+
+// parseDuration разбирает длительность RFC 5545: [+|-]P[nW][nD][T[nH][nM][nS]].
+func parseDuration(s string) (time.Duration, error) {
+	orig := s
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("malformed duration %q", orig)
+	}
+	s = s[1:]
+
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}
+	var d time.Duration
+	for s != "" {
+		if s[0] == 'T' {
+			units = map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+			s = s[1:]
+			continue
+		}
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(s) {
+			return 0, fmt.Errorf("malformed duration %q", orig)
+		}
+		n, err := strconv.Atoi(s[:i])
+		unit, ok := units[s[i]]
+		if err != nil || !ok {
+			return 0, fmt.Errorf("malformed duration %q", orig)
+		}
+		d += time.Duration(n) * unit
+		s = s[i+1:]
+	}
+	return sign * d, nil
+}
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func unescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
@@ -0,0 +1,149 @@
+// Package ical читает и записывает события в формате iCalendar (RFC 5545).
+//
+// Поддерживается подмножество, которым обмениваются Outlook и Google Calendar:
+// VEVENT со свойствами UID, SUMMARY, DESCRIPTION, DTSTART, DTEND или DURATION,
+// RRULE, EXDATE, RECURRENCE-ID и вложенный VALARM с относительным TRIGGER.
+// Время с TZID переводится через базу часовых поясов IANA, описания VTIMEZONE
+// не разбираются и не записываются: TZID ссылается на пояс IANA по имени, как
+// разрешает RFC 7809.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+// ContentType - MIME-тип файлов iCalendar.
+const ContentType = "text/calendar; charset=utf-8"
+
+const (
+	prodID         = "-//mluchkin//calendar//RU"
+	dateTimeFormat = "20060102T150405Z"
+	// maxLineLength - предельная длина строки в октетах без CRLF.
+	maxLineLength = 75
+)
+
+// Encode записывает события в w как VCALENDAR; stamp используется как DTSTAMP.
+// События без UID выгружаются с UID, равным ID.
+func Encode(w io.Writer, events []storage.Event, stamp time.Time) error {
+	bw := bufio.NewWriter(w)
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:"+prodID)
+	writeLine(bw, "CALSCALE:GREGORIAN")
+	for _, event := range events {
+		encodeEvent(bw, event, stamp)
+	}
+	writeLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+func encodeEvent(w *bufio.Writer, event storage.Event, stamp time.Time) {
+	writeLine(w, "BEGIN:VEVENT")
+	uid := event.UID
+	if uid == "" {
+		uid = event.ID
+	}
+	writeLine(w, "UID:"+escapeText(uid))
+	writeLine(w, "DTSTAMP:"+formatTime(stamp))
+	loc, tzid := location(event)
+	writeLine(w, "DTSTART"+tzid+":"+formatTimeIn(event.Start, loc))
+	writeLine(w, "DTEND"+tzid+":"+formatTimeIn(event.End, loc))
+	writeLine(w, "SUMMARY:"+escapeText(event.Title))
+	if event.Description != "" {
+		writeLine(w, "DESCRIPTION:"+escapeText(event.Description))
+	}
+	if event.RRule != "" {
+		writeLine(w, "RRULE:"+strings.TrimPrefix(event.RRule, "RRULE:"))
+	}
+	if len(event.ExDates) > 0 {
+		exdates := make([]string, 0, len(event.ExDates))
+		for _, exdate := range event.ExDates {
+			exdates = append(exdates, formatTimeIn(exdate, loc))
+		}
+		writeLine(w, "EXDATE"+tzid+":"+strings.Join(exdates, ","))
+	}
+	if event.NotifyBefore > 0 {
+		writeLine(w, "BEGIN:VALARM")
+		writeLine(w, "ACTION:DISPLAY")
+		writeLine(w, "DESCRIPTION:"+escapeText(event.Title))
+		writeLine(w, "TRIGGER:-"+formatDuration(event.NotifyBefore))
+		writeLine(w, "END:VALARM")
+	}
+	writeLine(w, "END:VEVENT")
+}
+
+// writeLine записывает строку содержимого, перенося её по RFC 5545: не длиннее
+// 75 октетов, продолжение начинается с пробела. Символы UTF-8 не разрываются.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// Ведущий пробел продолжения тоже занимает октет.
+		limit = maxLineLength - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(dateTimeFormat)
+}
+
+// location возвращает пояс, в котором записывается время события, и параметр TZID.
+// Время события с часовым поясом записывается по местному времени, чтобы повторения
+// серии не сдвигались при переходе на летнее время; время остальных событий - в UTC.
+func location(event storage.Event) (*time.Location, string) {
+	loc, err := storage.LoadLocation(event.TimeZone)
+	if event.TimeZone == "" || err != nil {
+		return time.UTC, ""
+	}
+	return loc, ";TZID=" + event.TimeZone
+}
+
+func formatTimeIn(t time.Time, loc *time.Location) string {
+	if loc == time.UTC {
+		return formatTime(t)
+	}
+	return t.In(loc).Format(localDateTimeFormat)
+}
+
+// formatDuration записывает длительность в виде PT1H30M, с точностью до секунды.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	var b strings.Builder
+	b.WriteString("PT")
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+		d -= m * time.Minute
+	}
+	if s := d / time.Second; s > 0 || b.Len() == 2 {
+		fmt.Fprintf(&b, "%dS", s)
+	}
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2021, time.September, 6, 10, 0, 0, 0, time.UTC)
+
+func TestEncodeDecode(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	local := start.In(berlin)
+
+	events := []storage.Event{
+		{
+			ID: "1", UID: "standup", Title: "Standup; daily, short", Start: local, End: local.Add(15 * time.Minute),
+			Description:  "Line one\nline two \\ " + strings.Repeat("очень длинное описание ", 10),
+			NotifyBefore: 90 * time.Minute, RRule: "FREQ=WEEKLY;BYDAY=MO,WE",
+			ExDates: []time.Time{local.AddDate(0, 0, 2)}, TimeZone: "Europe/Berlin",
+		},
+		{ID: "retro", Title: "Retro", Start: start.AddDate(0, 0, 4), End: start.AddDate(0, 0, 4).Add(time.Hour)},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, events, start))
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		require.LessOrEqual(t, len(line), maxLineLength, line)
+	}
+	require.Contains(t, buf.String(), "TRIGGER:-PT1H30M\r\n")
+	require.Contains(t, buf.String(), "SUMMARY:Standup\\; daily\\, short\r\n")
+	require.Contains(t, buf.String(), "DTSTART;TZID=Europe/Berlin:20210906T120000\r\n")
+	require.Contains(t, buf.String(), "EXDATE;TZID=Europe/Berlin:20210908T120000\r\n")
+	require.Contains(t, buf.String(), "DTSTART:20210910T100000Z\r\n")
+	require.Contains(t, buf.String(), "UID:standup\r\n")
+	// Событие без UID выгружается с UID, равным ID.
+	require.Contains(t, buf.String(), "UID:retro\r\n")
+
+	// ID не переносится: его назначает приложение при импорте.
+	events[0].ID, events[1].ID, events[1].UID = "", "", "retro"
+	decoded, err := Decode(&buf)
+	require.NoError(t, err)
+	require.Equal(t, events, decoded)
+}
+
+func TestDecode(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Google Inc//Google Calendar 70.9054//EN",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Moscow",
+		"BEGIN:STANDARD",
+		"DTSTART:19700101T000000",
+		"TZOFFSETFROM:+0300",
+		"TZOFFSETTO:+0300",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:series@example.com",
+		`DTSTART;TZID="Europe/Moscow":20210906T100000`,
+		"DURATION:PT45M",
+		"RRULE:FREQ=DAILY;COUNT=5",
+		"EXDATE;TZID=Europe/Moscow:20210907T100000,20210908T100000",
+		"SUMMARY:Daily sync",
+		"DESCRIPTION:Agenda:\\n- status\\n- blockers with a very long line that is fo",
+		" lded by the client",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER;RELATED=START:-P1DT2H",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:series@example.com",
+		"RECURRENCE-ID;TZID=Europe/Moscow:20210909T100000",
+		"DTSTART:20210909T120000Z",
+		"DTEND:20210909T124500Z",
+		"SUMMARY:Daily sync (moved)",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:holiday",
+		"DTSTART;VALUE=DATE:20210910",
+		"SUMMARY:Day off",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"UID:todo",
+		"SUMMARY:ignored",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := Decode(strings.NewReader(ics))
+	require.NoError(t, err)
+
+	seriesStart := time.Date(2021, time.September, 6, 10, 0, 0, 0, moscow)
+	require.Equal(t, []storage.Event{
+		{
+			UID: "series@example.com", Title: "Daily sync", Start: seriesStart, End: seriesStart.Add(45 * time.Minute),
+			Description:  "Agenda:\n- status\n- blockers with a very long line that is folded by the client",
+			NotifyBefore: 26 * time.Hour, RRule: "FREQ=DAILY;COUNT=5",
+			ExDates:  []time.Time{seriesStart.AddDate(0, 0, 1), seriesStart.AddDate(0, 0, 2), seriesStart.AddDate(0, 0, 3)},
+			TimeZone: "Europe/Moscow",
+		},
+		{
+			UID: "holiday", Title: "Day off",
+			Start: time.Date(2021, time.September, 10, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2021, time.September, 11, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			UID: "series@example.com-20210909T070000Z", Title: "Daily sync (moved)",
+			Start: time.Date(2021, time.September, 9, 12, 0, 0, 0, time.UTC),
+			End:   time.Date(2021, time.September, 9, 12, 45, 0, 0, time.UTC),
+		},
+	}, events)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for name, ics := range map[string]string{
+		"empty":          "",
+		"not a calendar": "BEGIN:VEVENT\r\nEND:VEVENT\r\n",
+		"unterminated":   "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\n",
+		"mismatched end": "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n",
+		"malformed line": "BEGIN:VCALENDAR\r\nGARBAGE\r\nEND:VCALENDAR\r\n",
+		"no start":       "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		"bad start":      "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:tomorrow\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		"unknown zone": "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;TZID=Mars/Olympus:20210906T100000\r\n" +
+			"END:VEVENT\r\nEND:VCALENDAR\r\n",
+		"bad duration": "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:20210906T100000Z\r\nDURATION:1H\r\n" +
+			"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		_, err := Decode(strings.NewReader(ics))
+		require.ErrorIs(t, err, ErrInvalidCalendar, name)
+	}
+}
@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const timeFormat = time.RFC3339
+
+// encodeText формирует запись вида: 2021-09-06T10:00:00Z INFO message key=value.
+func encodeText(t time.Time, level Level, msg string, fields []field) []byte {
+	var b bytes.Buffer
+	b.WriteString(t.Format(timeFormat))
+	b.WriteByte(' ')
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for _, f := range fields {
+		b.WriteByte(' ')
+		b.WriteString(f.key)
+		b.WriteByte('=')
+		b.WriteString(textValue(f.value))
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+func textValue(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func encodeJSON(t time.Time, level Level, msg string, fields []field) []byte {
+	entry := make(map[string]interface{}, len(fields)+3)
+	for _, f := range fields {
+		switch v := f.value.(type) {
+		case error:
+			entry[f.key] = v.Error()
+		case fmt.Stringer:
+			entry[f.key] = v.String()
+		default:
+			entry[f.key] = v
+		}
+	}
+	entry["time"] = t.Format(timeFormat)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		data, _ = json.Marshal(map[string]interface{}{
+			"time":  entry["time"],
+			"level": entry["level"],
+			"msg":   msg,
+			"error": "marshal log fields: " + err.Error(),
+		})
+	}
+	return append(data, '\n')
+}
@@ -1,20 +1,224 @@
 package logger
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-type Logger struct { // TODO
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+)
+
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int32(l))
+}
+
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+type Logger struct {
+	// level и out разделяются всеми логгерами, порождёнными через With.
+	level  *int32
+	out    *output
+	format Format
+	fields []field
+	now    func() time.Time
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (o *output) write(p []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.w.Write(p)
+}
+
+type Option func(l *Logger)
+
+// WithOutput задаёт назначение записей, по умолчанию os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(l *Logger) {
+		l.out = &output{w: w}
+	}
+}
+
+// WithFormat задаёт формат записей: text (по умолчанию) или json.
+func WithFormat(format Format) Option {
+	return func(l *Logger) {
+		l.format = format
+	}
 }
 
-func New(level string) *Logger {
-	return &Logger{}
+func New(level string, opts ...Option) *Logger {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		lvl = LevelInfo
+	}
+
+	l := &Logger{
+		level:  new(int32),
+		out:    &output{w: os.Stdout},
+		format: FormatText,
+		now:    time.Now,
+	}
+	atomic.StoreInt32(l.level, int32(lvl))
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+func ParseLevel(level string) (Level, error) {
+	switch strings.ToUpper(level) {
+	case "DEBUG":
+		return LevelDebug, nil
+	case "INFO":
+		return LevelInfo, nil
+	case "WARN", "WARNING":
+		return LevelWarn, nil
+	case "ERROR":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", level)
 }
 
-func (l Logger) Info(msg string) {
-	fmt.Println(msg)
+func ParseFormat(format string) (Format, error) {
+	switch f := Format(strings.ToLower(format)); f {
+	case FormatText, FormatJSON:
+		return f, nil
+	}
+	return FormatText, fmt.Errorf("unknown log format %q", format)
 }
 
-func (l Logger) Error(msg string) {
-	// TODO
+// SetLevel меняет уровень логирования, безопасно для конкурентного использования.
+func (l *Logger) SetLevel(level string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(l.level, int32(lvl))
+	return nil
 }
 
-// TODO
+// With возвращает логгер, добавляющий к каждой записи пары ключ-значение.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	child := *l
+	child.fields = make([]field, len(l.fields), len(l.fields)+(len(keyvals)+1)/2)
+	copy(child.fields, l.fields)
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		var value interface{} = "MISSING"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		child.fields = append(child.fields, field{key: key, value: value})
+	}
+	return &child
+}
+
+// WithContext возвращает логгер, добавляющий к записям ID запроса и пользователя из ctx.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	keyvals := make([]interface{}, 0, 4)
+	if id := reqctx.RequestID(ctx); id != "" {
+		keyvals = append(keyvals, "request_id", id)
+	}
+	if id := reqctx.UserID(ctx); id != "" {
+		keyvals = append(keyvals, "user_id", id)
+	}
+	if len(keyvals) == 0 {
+		return l
+	}
+	return l.With(keyvals...)
+}
+
+func (l *Logger) Debug(msg string) {
+	l.log(LevelDebug, msg)
+}
+
+func (l *Logger) Info(msg string) {
+	l.log(LevelInfo, msg)
+}
+
+func (l *Logger) Warn(msg string) {
+	l.log(LevelWarn, msg)
+}
+
+func (l *Logger) Error(msg string) {
+	l.log(LevelError, msg)
+}
+
+func (l *Logger) DebugContext(ctx context.Context, msg string, keyvals ...interface{}) {
+	l.logContext(ctx, LevelDebug, msg, keyvals)
+}
+
+func (l *Logger) InfoContext(ctx context.Context, msg string, keyvals ...interface{}) {
+	l.logContext(ctx, LevelInfo, msg, keyvals)
+}
+
+func (l *Logger) WarnContext(ctx context.Context, msg string, keyvals ...interface{}) {
+	l.logContext(ctx, LevelWarn, msg, keyvals)
+}
+
+func (l *Logger) ErrorContext(ctx context.Context, msg string, keyvals ...interface{}) {
+	l.logContext(ctx, LevelError, msg, keyvals)
+}
+
+func (l *Logger) enabled(level Level) bool {
+	return level >= Level(atomic.LoadInt32(l.level))
+}
+
+func (l *Logger) logContext(ctx context.Context, level Level, msg string, keyvals []interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+	l.WithContext(ctx).With(keyvals...).log(level, msg)
+}
+
+func (l *Logger) log(level Level, msg string) {
+	if !l.enabled(level) {
+		return
+	}
+
+	var entry []byte
+	if l.format == FormatJSON {
+		entry = encodeJSON(l.now(), level, msg, l.fields)
+	} else {
+		entry = encodeText(l.now(), level, msg, l.fields)
+	}
+	l.out.write(entry)
+}
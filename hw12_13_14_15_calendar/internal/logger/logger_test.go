@@ -1,7 +1,134 @@
 package logger
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLogger(level string, opts ...Option) (*Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	l := New(level, append([]Option{WithOutput(&buf)}, opts...)...)
+	l.now = func() time.Time {
+		return time.Date(2021, time.September, 6, 10, 0, 0, 0, time.UTC)
+	}
+	return l, &buf
+}
+
+func logAll(l *Logger) {
+	l.Debug("debug")
+	l.Info("info")
+	l.Warn("warn")
+	l.Error("error")
+}
 
 func TestLogger(t *testing.T) {
-	// TODO
+	t.Run("levels", func(t *testing.T) {
+		tests := []struct {
+			level    string
+			expected []string
+		}{
+			{level: "debug", expected: []string{"DEBUG debug", "INFO info", "WARN warn", "ERROR error"}},
+			{level: "INFO", expected: []string{"INFO info", "WARN warn", "ERROR error"}},
+			{level: "warn", expected: []string{"WARN warn", "ERROR error"}},
+			{level: "error", expected: []string{"ERROR error"}},
+		}
+
+		for _, tc := range tests {
+			tc := tc
+			t.Run(tc.level, func(t *testing.T) {
+				l, buf := newTestLogger(tc.level)
+				logAll(l)
+
+				lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+				require.Len(t, lines, len(tc.expected))
+				for i, line := range lines {
+					require.Equal(t, "2021-09-06T10:00:00Z "+tc.expected[i], line)
+				}
+			})
+		}
+	})
+
+	t.Run("set level", func(t *testing.T) {
+		l, buf := newTestLogger("error")
+		child := l.With("key", "value")
+
+		child.Info("skipped")
+		require.Empty(t, buf.String())
+
+		require.NoError(t, l.SetLevel("info"))
+		child.Info("logged")
+		require.Contains(t, buf.String(), "logged")
+
+		require.Error(t, l.SetLevel("verbose"))
+	})
+
+	t.Run("text fields", func(t *testing.T) {
+		l, buf := newTestLogger("info")
+		l.With("event_id", 42).With("err", errors.New("date is busy"), "empty", "").Info("created")
+
+		require.Equal(t,
+			"2021-09-06T10:00:00Z INFO created event_id=42 err=\"date is busy\" empty=\"\"\n",
+			buf.String())
+	})
+
+	t.Run("with does not mutate parent", func(t *testing.T) {
+		l, buf := newTestLogger("info")
+		base := l.With("a", 1)
+		base.With("b", 2).Info("child")
+		base.Info("parent")
+
+		require.Equal(t,
+			"2021-09-06T10:00:00Z INFO child a=1 b=2\n2021-09-06T10:00:00Z INFO parent a=1\n",
+			buf.String())
+	})
+
+	t.Run("context", func(t *testing.T) {
+		l, buf := newTestLogger("debug")
+		ctx := reqctx.WithUserID(reqctx.WithRequestID(context.Background(), "req-1"), "user-1")
+
+		l.InfoContext(ctx, "created", "event_id", "1")
+		l.DebugContext(context.Background(), "no ids")
+		require.NoError(t, l.SetLevel("warn"))
+		l.InfoContext(ctx, "skipped")
+
+		require.Equal(t,
+			"2021-09-06T10:00:00Z INFO created request_id=req-1 user_id=user-1 event_id=1\n"+
+				"2021-09-06T10:00:00Z DEBUG no ids\n",
+			buf.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		l, buf := newTestLogger("info", WithFormat(FormatJSON))
+		l.With("event_id", "1", "duration", time.Second).Warn("slow")
+
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		require.Equal(t, map[string]interface{}{
+			"time":     "2021-09-06T10:00:00Z",
+			"level":    "warn",
+			"msg":      "slow",
+			"event_id": "1",
+			"duration": "1s",
+		}, entry)
+	})
+}
+
+func TestParse(t *testing.T) {
+	_, err := ParseLevel("trace")
+	require.Error(t, err)
+
+	f, err := ParseFormat("JSON")
+	require.NoError(t, err)
+	require.Equal(t, FormatJSON, f)
+
+	_, err = ParseFormat("xml")
+	require.Error(t, err)
 }
@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"io"
+	"os"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+type OutputConf struct {
+	// Path - stdout, stderr или путь к файлу лога.
+	Path string
+	// MaxSize - размер файла в мегабайтах, после которого он ротируется.
+	MaxSize    int
+	MaxBackups int
+	// MaxAge - сколько дней хранить ротированные файлы.
+	MaxAge int
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// NewOutput возвращает назначение для записей лога: stdout, stderr или файл с ротацией.
+func NewOutput(conf OutputConf) io.WriteCloser {
+	switch conf.Path {
+	case "", "stdout":
+		return nopCloser{os.Stdout}
+	case "stderr":
+		return nopCloser{os.Stderr}
+	}
+	return &lumberjack.Logger{
+		Filename:   conf.Path,
+		MaxSize:    conf.MaxSize,
+		MaxBackups: conf.MaxBackups,
+		MaxAge:     conf.MaxAge,
+	}
+}
@@ -0,0 +1,407 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+
+	// attemptsHeader - заголовок с числом неудачных попыток обработать сообщение.
+	attemptsHeader = "x-attempts"
+)
+
+// ErrNotConfirmed возвращается, если брокер отказался принять опубликованное сообщение.
+var ErrNotConfirmed = errors.New("message not confirmed by broker")
+
+// Config - параметры подключения к RabbitMQ.
+type Config struct {
+	URL string
+	// Exchange - direct-exchange, в который публикуются сообщения.
+	Exchange string
+	// Queue - очередь, привязанная к Exchange с ключом маршрутизации, равным её имени.
+	Queue string
+	// RetryDelay и MaxAttempts задают повторную доставку так же, как в MemoryConfig:
+	// задержка удваивается с каждой попыткой, но не превышает 30 секунд, а после
+	// MaxAttempts попыток сообщение отбрасывается. По умолчанию - секунда и 5 попыток.
+	RetryDelay  time.Duration
+	MaxAttempts int
+}
+
+// AMQPPublisher публикует сообщения в RabbitMQ и дожидается их подтверждения брокером.
+// При разрыве соединения переподключается и публикует сообщение повторно.
+type AMQPPublisher struct {
+	mu      sync.Mutex
+	session *session
+}
+
+var _ Publisher = (*AMQPPublisher)(nil)
+
+func NewAMQPPublisher(logger Logger, conf Config) *AMQPPublisher {
+	return &AMQPPublisher{session: newSession(logger, conf, func(channel *amqp.Channel) error {
+		return channel.Confirm(false)
+	})}
+}
+
+// Connect подключается к брокеру, пока это не удастся или не будет отменён ctx,
+// и объявляет exchange и очередь, если их ещё нет.
+func (p *AMQPPublisher) Connect(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.session.open(ctx)
+}
+
+func (p *AMQPPublisher) Close() error {
+	return p.session.shutdown()
+}
+
+func (p *AMQPPublisher) Publish(ctx context.Context, body []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		if err := p.session.open(ctx); err != nil {
+			return err
+		}
+
+		err := p.session.channel.Publish(p.session.conf.Exchange, p.session.conf.Queue, false, false, amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         body,
+		})
+		if err == nil {
+			select {
+			case confirm, ok := <-p.session.confirms:
+				if ok && confirm.Ack {
+					return nil
+				}
+				if ok {
+					return ErrNotConfirmed
+				}
+				err = errors.New("channel closed before confirmation")
+			case <-ctx.Done():
+				// Неполученное подтверждение нарушило бы соответствие подтверждений
+				// публикациям, поэтому канал открывается заново.
+				p.session.close()
+				return ctx.Err()
+			}
+		}
+		if p.session.alive() {
+			return fmt.Errorf("publish: %w", err)
+		}
+		p.session.logger.WarnContext(ctx, "amqp publish failed, reconnecting", "err", err)
+		p.session.close()
+	}
+}
+
+// AMQPConsumer читает сообщения из очереди RabbitMQ, переподключаясь при разрыве соединения.
+// Необработанное сообщение откладывается в очередь задержки Queue.retry.<задержка>:
+// по истечении TTL брокер возвращает его в Queue.
+type AMQPConsumer struct {
+	session *session
+	retry   retryPolicy
+}
+
+var _ Consumer = (*AMQPConsumer)(nil)
+
+// channelPublisher - часть amqp.Channel, через которую откладываются сообщения.
+type channelPublisher interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+func NewAMQPConsumer(logger Logger, conf Config) *AMQPConsumer {
+	retry := newRetryPolicy(conf.RetryDelay, conf.MaxAttempts)
+	return &AMQPConsumer{retry: retry, session: newSession(logger, conf, func(channel *amqp.Channel) error {
+		if err := declareRetry(channel, conf, retry); err != nil {
+			return err
+		}
+		// Пока сообщение не подтверждено, следующее не выдаётся.
+		return channel.Qos(1, 0, false)
+	})}
+}
+
+// Connect подключается к брокеру, пока это не удастся или не будет отменён ctx,
+// и объявляет exchange и очередь, если их ещё нет.
+func (c *AMQPConsumer) Connect(ctx context.Context) error {
+	return c.session.open(ctx)
+}
+
+func (c *AMQPConsumer) Close() error {
+	return c.session.shutdown()
+}
+
+func (c *AMQPConsumer) Consume(ctx context.Context, handle Handler) error {
+	for {
+		if err := c.session.open(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		deliveries, err := c.session.channel.Consume(c.session.conf.Queue, "", false, false, false, false, nil)
+		if err != nil && c.session.alive() {
+			return fmt.Errorf("consume %q: %w", c.session.conf.Queue, err)
+		}
+		if err == nil {
+			c.consume(ctx, deliveries, handle)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		c.session.logger.WarnContext(ctx, "amqp channel closed, reconnecting")
+		c.session.close()
+	}
+}
+
+// consume обрабатывает сообщения, пока не отменён ctx или не закрыт канал.
+// Неподтверждённые из-за разрыва сообщения брокер доставит повторно.
+func (c *AMQPConsumer) consume(ctx context.Context, deliveries <-chan amqp.Delivery, handle Handler) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d, ok := <-deliveries:
+			if !ok {
+				return
+			}
+			if err := c.settle(ctx, c.session.channel, d, handle(ctx, d.Body)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// settle завершает обработку сообщения с результатом err: подтверждает его, отбрасывает
+// при ErrDiscard или исчерпав попытки, а иначе публикует в очередь задержки с увеличенным
+// счётчиком попыток и подтверждает исходное.
+func (c *AMQPConsumer) settle(ctx context.Context, channel channelPublisher, d amqp.Delivery, err error) error {
+	attempts := deliveryAttempts(d) + 1
+	switch {
+	case err == nil:
+		return d.Ack(false)
+	case errors.Is(err, ErrDiscard):
+		return d.Reject(false)
+	case attempts >= c.retry.maxAttempts:
+		c.session.logger.WarnContext(ctx, "message discarded after failed attempts", "attempts", attempts, "err", err)
+		return d.Reject(false)
+	}
+
+	headers := make(amqp.Table, len(d.Headers)+1)
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[attemptsHeader] = int32(attempts)
+	queue := retryQueue(c.session.conf.Queue, c.retry.after(attempts))
+	if err := channel.Publish("", queue, false, false, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		DeliveryMode: d.DeliveryMode,
+		Body:         d.Body,
+	}); err != nil {
+		// Неподтверждённое сообщение брокер доставит повторно после переподключения.
+		return fmt.Errorf("publish to %q: %w", queue, err)
+	}
+	return d.Ack(false)
+}
+
+// deliveryAttempts возвращает число предыдущих неудачных попыток обработать сообщение.
+func deliveryAttempts(d amqp.Delivery) int {
+	switch n := d.Headers[attemptsHeader].(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+// session - соединение с брокером и канал с объявленными exchange и очередью.
+// Методы session не потокобезопасны, кроме shutdown.
+type session struct {
+	logger Logger
+	conf   Config
+	// setup дополнительно настраивает новый канал.
+	setup func(channel *amqp.Channel) error
+
+	// connMu защищает conn от конкурентного shutdown.
+	connMu   sync.Mutex
+	conn     *amqp.Connection
+	channel  *amqp.Channel
+	closed   chan *amqp.Error
+	confirms chan amqp.Confirmation
+
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func newSession(logger Logger, conf Config, setup func(channel *amqp.Channel) error) *session {
+	return &session{
+		logger: logger,
+		conf:   conf,
+		setup:  setup,
+		done:   make(chan struct{}),
+	}
+}
+
+// open возвращает управление, когда канал готов к работе. Пока брокер недоступен,
+// попытки подключения повторяются с растущей задержкой до отмены ctx.
+func (s *session) open(ctx context.Context) error {
+	if s.alive() {
+		return nil
+	}
+	s.close()
+
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-s.done:
+			return ErrClosed
+		default:
+		}
+
+		err := s.dial()
+		if err == nil {
+			return nil
+		}
+		delay := backoff(attempt)
+		s.logger.WarnContext(ctx, "failed to connect to amqp", "err", err, "retry_in", delay)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("connect to amqp: %w", err)
+		case <-s.done:
+			return ErrClosed
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (s *session) dial() error {
+	conn, err := amqp.Dial(s.conf.URL)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("open channel: %w", err)
+	}
+	if err := declare(channel, s.conf); err != nil {
+		conn.Close()
+		return err
+	}
+	if err := s.setup(channel); err != nil {
+		conn.Close()
+		return fmt.Errorf("setup channel: %w", err)
+	}
+
+	s.connMu.Lock()
+	s.conn = conn
+	s.connMu.Unlock()
+	s.channel = channel
+	s.closed = channel.NotifyClose(make(chan *amqp.Error, 1))
+	s.confirms = channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+	return nil
+}
+
+func (s *session) alive() bool {
+	if s.channel == nil {
+		return false
+	}
+	select {
+	case <-s.closed:
+		return false
+	default:
+		return true
+	}
+}
+
+func (s *session) close() {
+	s.connMu.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.conn = nil
+	s.connMu.Unlock()
+	s.channel, s.closed, s.confirms = nil, nil, nil
+}
+
+// shutdown прерывает переподключение и закрывает соединение.
+func (s *session) shutdown() error {
+	s.doneOnce.Do(func() { close(s.done) })
+
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if s.conn == nil || s.conn.IsClosed() {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// backoff возвращает задержку перед очередной попыткой подключения:
+// она удваивается с каждой попыткой, но не превышает maxReconnectDelay.
+func backoff(attempt int) time.Duration {
+	delay := minReconnectDelay
+	for i := 0; i < attempt && delay < maxReconnectDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxReconnectDelay {
+		delay = maxReconnectDelay
+	}
+	return delay
+}
+
+func declare(channel *amqp.Channel, conf Config) error {
+	if err := channel.ExchangeDeclare(conf.Exchange, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare exchange %q: %w", conf.Exchange, err)
+	}
+	if _, err := channel.QueueDeclare(conf.Queue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare queue %q: %w", conf.Queue, err)
+	}
+	if err := channel.QueueBind(conf.Queue, conf.Queue, conf.Exchange, false, nil); err != nil {
+		return fmt.Errorf("bind queue %q: %w", conf.Queue, err)
+	}
+	return nil
+}
+
+// declareRetry объявляет очереди задержки для всех попыток: сообщения в них живут
+// одну задержку, а затем через Exchange возвращаются в основную очередь. Задержка
+// входит в имя очереди, поэтому её изменение не конфликтует с объявленными ранее.
+func declareRetry(channel *amqp.Channel, conf Config, retry retryPolicy) error {
+	for _, delay := range retryDelays(retry) {
+		queue := retryQueue(conf.Queue, delay)
+		_, err := channel.QueueDeclare(queue, true, false, false, false, amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    conf.Exchange,
+			"x-dead-letter-routing-key": conf.Queue,
+		})
+		if err != nil {
+			return fmt.Errorf("declare queue %q: %w", queue, err)
+		}
+	}
+	return nil
+}
+
+// retryDelays возвращает различные задержки перед повторными доставками по возрастанию.
+func retryDelays(retry retryPolicy) []time.Duration {
+	var delays []time.Duration
+	for attempt := 1; attempt < retry.maxAttempts; attempt++ {
+		if delay := retry.after(attempt); len(delays) == 0 || delays[len(delays)-1] != delay {
+			delays = append(delays, delay)
+		}
+	}
+	return delays
+}
+
+func retryQueue(queue string, delay time.Duration) string {
+	return queue + ".retry." + delay.String()
+}
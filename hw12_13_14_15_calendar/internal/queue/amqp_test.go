@@ -0,0 +1,109 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/require"
+)
+
+// acknowledger запоминает, как завершена обработка сообщения.
+type acknowledger struct {
+	result string
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.result = "ack"
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.result = "nack"
+	return nil
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	a.result = "reject"
+	if requeue {
+		a.result = "requeue"
+	}
+	return nil
+}
+
+type publishing struct {
+	key string
+	msg amqp.Publishing
+}
+
+type channelRecorder []publishing
+
+func (r *channelRecorder) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	*r = append(*r, publishing{key: key, msg: msg})
+	return nil
+}
+
+func TestBackoff(t *testing.T) {
+	require.Equal(t, time.Second, backoff(0))
+	require.Equal(t, 2*time.Second, backoff(1))
+	require.Equal(t, 16*time.Second, backoff(4))
+	require.Equal(t, maxReconnectDelay, backoff(5))
+	require.Equal(t, maxReconnectDelay, backoff(100))
+}
+
+func TestAMQPSettle(t *testing.T) {
+	ctx := context.Background()
+	logg := logger.New("error", logger.WithOutput(io.Discard))
+	c := NewAMQPConsumer(logg, Config{
+		Exchange: "calendar", Queue: "notifications", RetryDelay: time.Second, MaxAttempts: 3,
+	})
+	failure := errors.New("temporary failure")
+
+	settle := func(headers amqp.Table, err error) (string, channelRecorder) {
+		var ack acknowledger
+		var channel channelRecorder
+		d := amqp.Delivery{Acknowledger: &ack, Headers: headers, Body: []byte("body"), DeliveryMode: amqp.Persistent}
+		require.NoError(t, c.settle(ctx, &channel, d, err))
+		return ack.result, channel
+	}
+
+	result, channel := settle(nil, nil)
+	require.Equal(t, "ack", result)
+	require.Empty(t, channel)
+
+	result, channel = settle(nil, ErrDiscard)
+	require.Equal(t, "reject", result)
+	require.Empty(t, channel)
+
+	// Необработанное сообщение откладывается с растущей задержкой, а не возвращается в очередь сразу.
+	result, channel = settle(amqp.Table{"trace": "1"}, failure)
+	require.Equal(t, "ack", result)
+	require.Len(t, channel, 1)
+	require.Equal(t, "notifications.retry.1s", channel[0].key)
+	require.Equal(t, amqp.Table{"trace": "1", attemptsHeader: int32(1)}, channel[0].msg.Headers)
+	require.Equal(t, []byte("body"), channel[0].msg.Body)
+	require.Equal(t, amqp.Persistent, channel[0].msg.DeliveryMode)
+
+	result, channel = settle(amqp.Table{attemptsHeader: int64(1)}, failure)
+	require.Equal(t, "ack", result)
+	require.Len(t, channel, 1)
+	require.Equal(t, "notifications.retry.2s", channel[0].key)
+	require.Equal(t, int32(2), channel[0].msg.Headers[attemptsHeader])
+
+	// После MaxAttempts попыток сообщение отбрасывается.
+	result, channel = settle(amqp.Table{attemptsHeader: int32(2)}, failure)
+	require.Equal(t, "reject", result)
+	require.Empty(t, channel)
+}
+
+func TestRetryDelays(t *testing.T) {
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second},
+		retryDelays(newRetryPolicy(0, 3)))
+	require.Equal(t, []time.Duration{10 * time.Second, 20 * time.Second, maxRetryDelay},
+		retryDelays(newRetryPolicy(10*time.Second, 8)))
+	require.Empty(t, retryDelays(newRetryPolicy(time.Second, 1)))
+}
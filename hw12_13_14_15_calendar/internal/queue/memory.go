@@ -0,0 +1,170 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MemoryConfig - параметры повторной доставки сообщений Memory.
+type MemoryConfig struct {
+	// RetryDelay - задержка перед первой повторной доставкой, с каждой попыткой
+	// она удваивается, но не превышает 30 секунд. По умолчанию - секунда.
+	RetryDelay time.Duration
+	// MaxAttempts - число попыток обработать сообщение, после которого оно
+	// отбрасывается. По умолчанию - 5.
+	MaxAttempts int
+}
+
+// Memory - очередь в памяти процесса, реализует Publisher и Consumer.
+type Memory struct {
+	logger Logger
+	retry  retryPolicy
+
+	mu       sync.Mutex
+	messages []message
+	closed   bool
+	// ready сигнализирует ожидающим получателям о новом сообщении.
+	ready chan struct{}
+	done  chan struct{}
+}
+
+// message - сообщение очереди с числом попыток его обработать.
+type message struct {
+	body     []byte
+	attempts int
+	// notBefore - момент, раньше которого сообщение не доставляется повторно.
+	notBefore time.Time
+}
+
+var (
+	_ Publisher = (*Memory)(nil)
+	_ Consumer  = (*Memory)(nil)
+)
+
+func NewMemory(logger Logger, conf MemoryConfig) *Memory {
+	return &Memory{
+		logger: logger,
+		retry:  newRetryPolicy(conf.RetryDelay, conf.MaxAttempts),
+		ready:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+func (m *Memory) Publish(ctx context.Context, body []byte) error {
+	return m.push(message{body: append([]byte(nil), body...)})
+}
+
+// Consume передаёт сообщения handle до отмены ctx или закрытия очереди. Сообщение,
+// которое не удалось обработать, доставляется повторно с растущей задержкой,
+// а после MaxAttempts попыток отбрасывается.
+func (m *Memory) Consume(ctx context.Context, handle Handler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-m.done:
+			return nil
+		default:
+		}
+
+		msg, wait, ok := m.pop(time.Now())
+		if !ok {
+			if err := m.wait(ctx, wait); err != nil {
+				return nil
+			}
+			continue
+		}
+
+		msg.attempts++
+		err := handle(ctx, msg.body)
+		switch {
+		case err == nil, errors.Is(err, ErrDiscard):
+		case msg.attempts >= m.retry.maxAttempts:
+			m.logger.WarnContext(ctx, "message discarded after failed attempts", "attempts", msg.attempts, "err", err)
+		default:
+			msg.notBefore = time.Now().Add(m.retry.after(msg.attempts))
+			if err := m.push(msg); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// Close прекращает приём сообщений и завершает Consume; недоставленные сообщения теряются.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.closed {
+		m.closed = true
+		m.messages = nil
+		close(m.done)
+	}
+	return nil
+}
+
+// Len возвращает число сообщений, ожидающих обработки, включая повторные доставки.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.messages)
+}
+
+func (m *Memory) push(msg message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+	m.messages = append(m.messages, msg)
+	select {
+	case m.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// pop извлекает первое сообщение, которое можно доставить в момент now. Если таких нет,
+// возвращает время до ближайшей повторной доставки; 0 - очередь пуста.
+func (m *Memory) pop(now time.Time) (message, time.Duration, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var wait time.Duration
+	for i, msg := range m.messages {
+		if d := msg.notBefore.Sub(now); d > 0 {
+			if wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		copy(m.messages[i:], m.messages[i+1:])
+		m.messages[len(m.messages)-1] = message{}
+		m.messages = m.messages[:len(m.messages)-1]
+		return msg, 0, true
+	}
+	return message{}, wait, false
+}
+
+// wait ждёт нового сообщения или, если wait больше нуля, наступления повторной доставки.
+// Возвращает ошибку, если ctx отменён или очередь закрыта.
+func (m *Memory) wait(ctx context.Context, wait time.Duration) error {
+	var retry <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		retry = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.done:
+		return ErrClosed
+	case <-m.ready:
+	case <-retry:
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func newMemory(conf MemoryConfig) *Memory {
+	return NewMemory(logger.New("error", logger.WithOutput(io.Discard)), conf)
+}
+
+func TestMemory(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	q := newMemory(MemoryConfig{RetryDelay: time.Millisecond})
+	for _, body := range []string{"first", "poison", "second"} {
+		require.NoError(t, q.Publish(ctx, []byte(body)))
+	}
+
+	var (
+		received []string
+		failed   bool
+	)
+	err := q.Consume(ctx, func(ctx context.Context, body []byte) error {
+		received = append(received, string(body))
+		switch {
+		case string(body) == "poison":
+			return ErrDiscard
+		case string(body) == "first" && !failed:
+			// Необработанное сообщение возвращается в очередь.
+			failed = true
+			return errors.New("temporary failure")
+		}
+		if len(received) == 4 {
+			cancel()
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"first", "poison", "second", "first"}, received)
+	require.Zero(t, q.Len())
+}
+
+func TestMemoryRetry(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	// Без ограничения четвёртая попытка состоялась бы через 7 задержек после первой.
+	ctx, cancel := context.WithTimeout(context.Background(), 15*delay)
+	defer cancel()
+
+	q := newMemory(MemoryConfig{RetryDelay: delay, MaxAttempts: 3})
+	require.NoError(t, q.Publish(ctx, []byte("broken")))
+
+	// Повторные доставки идут с растущей задержкой, после третьей попытки сообщение отбрасывается.
+	var attempts []time.Time
+	require.NoError(t, q.Consume(ctx, func(ctx context.Context, body []byte) error {
+		attempts = append(attempts, time.Now())
+		return errors.New("permanent failure")
+	}))
+	require.Len(t, attempts, 3)
+	require.GreaterOrEqual(t, int64(attempts[1].Sub(attempts[0])), int64(delay))
+	require.GreaterOrEqual(t, int64(attempts[2].Sub(attempts[1])), int64(2*delay))
+	require.Zero(t, q.Len())
+}
+
+func TestMemoryCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := newMemory(MemoryConfig{RetryDelay: time.Nanosecond, MaxAttempts: 1000000})
+	require.NoError(t, q.Publish(ctx, []byte("broken")))
+
+	// Сообщение, которое всё время не обрабатывается, не мешает остановить получение.
+	done := make(chan error)
+	go func() {
+		done <- q.Consume(ctx, func(context.Context, []byte) error { return errors.New("failure") })
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("consume did not stop after cancel")
+	}
+	require.Equal(t, 1, q.Len())
+}
+
+func TestMemoryClose(t *testing.T) {
+	q := newMemory(MemoryConfig{})
+	done := make(chan error)
+	go func() {
+		done <- q.Consume(context.Background(), func(context.Context, []byte) error { return nil })
+	}()
+
+	require.NoError(t, q.Close())
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("consume did not stop after close")
+	}
+	require.ErrorIs(t, q.Publish(context.Background(), []byte("late")), ErrClosed)
+}
@@ -0,0 +1,75 @@
+// Package queue передаёт сообщения между процессами календаря. Планировщик и рассыльщик
+// работают с интерфейсами Publisher и Consumer и не зависят от конкретного брокера:
+// AMQPPublisher и AMQPConsumer работают с RabbitMQ, Memory - очередь в памяти процесса
+// для тестов и запуска всех компонентов в одном процессе.
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	defaultRetryDelay  = time.Second
+	defaultMaxAttempts = 5
+	maxRetryDelay      = 30 * time.Second
+)
+
+// ErrDiscard возвращается обработчиком сообщения, которое невозможно обработать
+// и не имеет смысла доставлять повторно.
+var ErrDiscard = errors.New("discard message")
+
+// ErrClosed возвращается при работе с закрытой очередью.
+var ErrClosed = errors.New("queue is closed")
+
+// Handler обрабатывает тело сообщения.
+type Handler func(ctx context.Context, body []byte) error
+
+type Publisher interface {
+	// Publish возвращает управление, когда очередь приняла сообщение.
+	Publish(ctx context.Context, body []byte) error
+	Close() error
+}
+
+type Consumer interface {
+	// Consume передаёт сообщения handle до отмены ctx. Сообщение подтверждается только
+	// после успешной обработки; при ErrDiscard оно отбрасывается, при прочих ошибках
+	// доставляется повторно с растущей задержкой, а исчерпав попытки - отбрасывается.
+	Consume(ctx context.Context, handle Handler) error
+	Close() error
+}
+
+type Logger interface {
+	WarnContext(ctx context.Context, msg string, keyvals ...interface{})
+}
+
+// retryPolicy - повторная доставка сообщений, которые не удалось обработать.
+type retryPolicy struct {
+	delay       time.Duration
+	maxAttempts int
+}
+
+// newRetryPolicy подставляет значения по умолчанию вместо неположительных параметров.
+func newRetryPolicy(delay time.Duration, maxAttempts int) retryPolicy {
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	return retryPolicy{delay: delay, maxAttempts: maxAttempts}
+}
+
+// after возвращает задержку перед повторной доставкой после attempt неудачных попыток:
+// она удваивается с каждой попыткой, но не превышает maxRetryDelay.
+func (p retryPolicy) after(attempt int) time.Duration {
+	delay := p.delay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,177 @@
+package recurrence
+
+import (
+	"sort"
+	"time"
+)
+
+// maxPeriods ограничивает перебор для правил, которые почти никогда не срабатывают.
+const maxPeriods = 100000
+
+// Between возвращает начала повторений серии с первым событием в dtstart,
+// попадающие в интервал [from, to).
+func (r Rule) Between(dtstart, from, to time.Time) []time.Time {
+	var result []time.Time
+	r.iterate(dtstart, to, func(t time.Time) bool {
+		if !t.Before(to) {
+			return false
+		}
+		if !t.Before(from) {
+			result = append(result, t)
+		}
+		return true
+	})
+	return result
+}
+
+// Last возвращает начало последнего повторения серии; ok = false, если серия бесконечна
+// или не заканчивается за maxPeriods периодов.
+func (r Rule) Last(dtstart time.Time) (last time.Time, ok bool) {
+	if r.Count == 0 && r.Until.IsZero() {
+		return time.Time{}, false
+	}
+	complete := r.iterate(dtstart, time.Time{}, func(t time.Time) bool {
+		last = t
+		return true
+	})
+	if !complete {
+		return time.Time{}, false
+	}
+	return last, true
+}
+
+// iterate передаёт yield начала повторений по возрастанию, пока yield возвращает true,
+// не исчерпаны COUNT и UNTIL и период не начинается позже limit (если limit задан).
+// Как и требует RFC 5545, dtstart всегда считается первым повторением.
+// Возвращает false, если перебор прерван на maxPeriods периодах.
+func (r Rule) iterate(dtstart, limit time.Time, yield func(time.Time) bool) bool {
+	count := 0
+	emit := func(t time.Time) bool {
+		if !r.Until.IsZero() && t.After(r.Until) {
+			return false
+		}
+		count++
+		return yield(t) && (r.Count == 0 || count < r.Count)
+	}
+
+	if !emit(dtstart) {
+		return true
+	}
+	for p := 0; p < maxPeriods; p++ {
+		start, candidates := r.period(dtstart, p)
+		if (!limit.IsZero() && start.After(limit)) || (!r.Until.IsZero() && start.After(r.Until)) {
+			return true
+		}
+		for _, t := range candidates {
+			if t.After(dtstart) && !emit(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// period возвращает начало p-го периода серии и начала повторений в нём по возрастанию.
+func (r Rule) period(dtstart time.Time, p int) (time.Time, []time.Time) {
+	step := p * r.Interval
+	switch r.Freq {
+	case Daily:
+		day := dtstart.AddDate(0, 0, step)
+		if len(r.ByDay) > 0 && !r.hasWeekday(day.Weekday()) {
+			return day, nil
+		}
+		return day, []time.Time{day}
+	case Weekly:
+		base := dtstart.AddDate(0, 0, 7*step)
+		monday := base.AddDate(0, 0, -daysSinceMonday(base.Weekday()))
+		if len(r.ByDay) == 0 {
+			return monday, []time.Time{base}
+		}
+		result := make([]time.Time, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			result = append(result, monday.AddDate(0, 0, daysSinceMonday(day.Day)))
+		}
+		return monday, sortUnique(result)
+	case Monthly:
+		first := at(dtstart, dtstart.Year(), dtstart.Month()+time.Month(step), 1)
+		if len(r.ByDay) == 0 {
+			return first, dayOfMonth(dtstart, first.Year(), first.Month(), dtstart.Day())
+		}
+		var result []time.Time
+		for _, day := range r.ByDay {
+			result = append(result, weekdaysOfMonth(dtstart, first.Year(), first.Month(), day)...)
+		}
+		return first, sortUnique(result)
+	case Yearly:
+		year := dtstart.Year() + step
+		return at(dtstart, year, time.January, 1), dayOfMonth(dtstart, year, dtstart.Month(), dtstart.Day())
+	}
+	return dtstart, nil
+}
+
+func (r Rule) hasWeekday(day time.Weekday) bool {
+	for _, d := range r.ByDay {
+		if d.Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+// at возвращает дату с временем суток и часовым поясом dtstart.
+func at(dtstart time.Time, year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day,
+		dtstart.Hour(), dtstart.Minute(), dtstart.Second(), dtstart.Nanosecond(), dtstart.Location())
+}
+
+// dayOfMonth возвращает указанный день месяца; несуществующие даты, например 31 апреля,
+// по RFC 5545 пропускаются.
+func dayOfMonth(dtstart time.Time, year int, month time.Month, day int) []time.Time {
+	if day > daysIn(year, month) {
+		return nil
+	}
+	return []time.Time{at(dtstart, year, month, day)}
+}
+
+func weekdaysOfMonth(dtstart time.Time, year int, month time.Month, wd Weekday) []time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(wd.Day) - int(first.Weekday()) + 7) % 7
+	var days []int
+	for d := 1 + offset; d <= daysIn(year, month); d += 7 {
+		days = append(days, d)
+	}
+
+	switch {
+	case wd.N > 0 && wd.N <= len(days):
+		days = days[wd.N-1 : wd.N]
+	case wd.N < 0 && -wd.N <= len(days):
+		days = days[len(days)+wd.N : len(days)+wd.N+1]
+	case wd.N != 0:
+		days = nil
+	}
+
+	result := make([]time.Time, 0, len(days))
+	for _, d := range days {
+		result = append(result, at(dtstart, year, month, d))
+	}
+	return result
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func daysSinceMonday(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
+
+func sortUnique(times []time.Time) []time.Time {
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	result := times[:0]
+	for i, t := range times {
+		if i == 0 || !t.Equal(times[i-1]) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
@@ -0,0 +1,221 @@
+// Package recurrence разбирает правила повторения RRULE (RFC 5545) и вычисляет
+// по ним моменты начала повторений события.
+//
+// Поддерживаются FREQ=DAILY|WEEKLY|MONTHLY|YEARLY, INTERVAL, COUNT, UNTIL и BYDAY
+// (для MONTHLY - в том числе с порядковым номером: 1MO, -1FR). Неделя начинается
+// с понедельника (WKST=MO).
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+type Frequency int
+
+const (
+	Daily Frequency = iota + 1
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencies = map[string]Frequency{
+	"DAILY":   Daily,
+	"WEEKLY":  Weekly,
+	"MONTHLY": Monthly,
+	"YEARLY":  Yearly,
+}
+
+func (f Frequency) String() string {
+	for name, freq := range frequencies {
+		if freq == f {
+			return name
+		}
+	}
+	return "UNKNOWN"
+}
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Weekday - элемент BYDAY: день недели и, для MONTHLY, его порядковый номер в месяце.
+// N = 0 означает каждый такой день, отрицательный N отсчитывается от конца месяца.
+type Weekday struct {
+	N   int
+	Day time.Weekday
+}
+
+func (w Weekday) String() string {
+	day := ""
+	for name, d := range weekdays {
+		if d == w.Day {
+			day = name
+		}
+	}
+	if w.N == 0 {
+		return day
+	}
+	return strconv.Itoa(w.N) + day
+}
+
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []Weekday
+	// Count - число повторений, 0 - без ограничения.
+	Count int
+	// Until - последний допустимый момент начала повторения, нулевое значение - без ограничения.
+	Until time.Time
+}
+
+// Parse разбирает значение RRULE, например "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10".
+func Parse(s string) (Rule, error) {
+	rule := Rule{Interval: 1}
+	for _, part := range strings.Split(strings.TrimPrefix(s, "RRULE:"), ";") {
+		name, value, ok := cut(part, "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+		if err := rule.set(strings.ToUpper(name), value); err != nil {
+			return Rule{}, err
+		}
+	}
+
+	switch {
+	case rule.Freq == 0:
+		return Rule{}, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	case rule.Count > 0 && !rule.Until.IsZero():
+		return Rule{}, fmt.Errorf("%w: COUNT and UNTIL are mutually exclusive", ErrInvalidRule)
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly {
+			return Rule{}, fmt.Errorf("%w: numbered BYDAY is supported only with FREQ=MONTHLY", ErrInvalidRule)
+		}
+	}
+	if len(rule.ByDay) > 0 && rule.Freq == Yearly {
+		return Rule{}, fmt.Errorf("%w: BYDAY is not supported with FREQ=YEARLY", ErrInvalidRule)
+	}
+	return rule, nil
+}
+
+func (r *Rule) set(name, value string) error {
+	var err error
+	switch name {
+	case "FREQ":
+		freq, ok := frequencies[strings.ToUpper(value)]
+		if !ok {
+			return fmt.Errorf("%w: unsupported FREQ %q", ErrInvalidRule, value)
+		}
+		r.Freq = freq
+	case "INTERVAL":
+		r.Interval, err = strconv.Atoi(value)
+		if err != nil || r.Interval < 1 {
+			return fmt.Errorf("%w: INTERVAL must be a positive integer", ErrInvalidRule)
+		}
+	case "COUNT":
+		r.Count, err = strconv.Atoi(value)
+		if err != nil || r.Count < 1 {
+			return fmt.Errorf("%w: COUNT must be a positive integer", ErrInvalidRule)
+		}
+	case "UNTIL":
+		r.Until, err = parseUntil(value)
+		if err != nil {
+			return fmt.Errorf("%w: UNTIL: %v", ErrInvalidRule, err)
+		}
+	case "BYDAY":
+		for _, item := range strings.Split(value, ",") {
+			day, err := parseWeekday(item)
+			if err != nil {
+				return err
+			}
+			r.ByDay = append(r.ByDay, day)
+		}
+	case "WKST":
+		if strings.ToUpper(value) != "MO" {
+			return fmt.Errorf("%w: only WKST=MO is supported", ErrInvalidRule)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported part %s", ErrInvalidRule, name)
+	}
+	return nil
+}
+
+// String возвращает правило в формате RRULE.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq.String()}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			days = append(days, day.String())
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(dateTimeFormat))
+	}
+	return strings.Join(parts, ";")
+}
+
+const (
+	dateFormat     = "20060102"
+	dateTimeFormat = "20060102T150405Z"
+)
+
+// parseUntil принимает UNTIL в виде даты-времени UTC или даты; дата означает
+// включительно весь день.
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse(dateTimeFormat, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(dateFormat, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+func parseWeekday(item string) (Weekday, error) {
+	item = strings.ToUpper(strings.TrimSpace(item))
+	if len(item) < 2 {
+		return Weekday{}, fmt.Errorf("%w: malformed BYDAY %q", ErrInvalidRule, item)
+	}
+	day, ok := weekdays[item[len(item)-2:]]
+	if !ok {
+		return Weekday{}, fmt.Errorf("%w: malformed BYDAY %q", ErrInvalidRule, item)
+	}
+	n := 0
+	if prefix := item[:len(item)-2]; prefix != "" {
+		var err error
+		n, err = strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return Weekday{}, fmt.Errorf("%w: malformed BYDAY %q", ErrInvalidRule, item)
+		}
+	}
+	return Weekday{N: n, Day: day}, nil
+}
+
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,142 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	rule, err := Parse("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;UNTIL=20211231T235959Z")
+	require.NoError(t, err)
+	require.Equal(t, Rule{
+		Freq:     Weekly,
+		Interval: 2,
+		ByDay:    []Weekday{{Day: time.Monday}, {Day: time.Wednesday}},
+		Until:    date(2021, time.December, 31, 23).Add(59*time.Minute + 59*time.Second),
+	}, rule)
+	require.Equal(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;UNTIL=20211231T235959Z", rule.String())
+
+	rule, err = Parse("RRULE:FREQ=MONTHLY;BYDAY=-1FR;COUNT=3")
+	require.NoError(t, err)
+	require.Equal(t, "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", rule.String())
+
+	for _, s := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;INTERVAL=-1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20211231",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=YEARLY;BYDAY=MO",
+		"FREQ=DAILY;BYHOUR=10",
+		"FREQ=DAILY;UNTIL=tomorrow",
+	} {
+		_, err := Parse(s)
+		require.ErrorIs(t, err, ErrInvalidRule, s)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	// 6 сентября 2021 - понедельник.
+	dtstart := date(2021, time.September, 6, 10)
+
+	tests := []struct {
+		name     string
+		rule     string
+		dtstart  time.Time
+		from, to time.Time
+		expected []time.Time
+	}{
+		{
+			name: "daily count", rule: "FREQ=DAILY;COUNT=3", dtstart: dtstart,
+			from: dtstart, to: dtstart.AddDate(0, 1, 0),
+			expected: []time.Time{dtstart, dtstart.AddDate(0, 0, 1), dtstart.AddDate(0, 0, 2)},
+		},
+		{
+			name: "daily by weekday", rule: "FREQ=DAILY;BYDAY=SA,SU", dtstart: dtstart,
+			from: dtstart, to: dtstart.AddDate(0, 0, 14),
+			expected: []time.Time{
+				dtstart, // DTSTART всегда первое повторение.
+				date(2021, time.September, 11, 10), date(2021, time.September, 12, 10),
+				date(2021, time.September, 18, 10), date(2021, time.September, 19, 10),
+			},
+		},
+		{
+			name: "weekly by day with range", rule: "FREQ=WEEKLY;BYDAY=MO,TH", dtstart: dtstart,
+			from: date(2021, time.September, 13, 0), to: date(2021, time.September, 20, 0),
+			expected: []time.Time{date(2021, time.September, 13, 10), date(2021, time.September, 16, 10)},
+		},
+		{
+			name: "biweekly until", rule: "FREQ=WEEKLY;INTERVAL=2;UNTIL=20211004", dtstart: dtstart,
+			from: dtstart, to: dtstart.AddDate(1, 0, 0),
+			expected: []time.Time{dtstart, date(2021, time.September, 20, 10), date(2021, time.October, 4, 10)},
+		},
+		{
+			name: "monthly skips short months", rule: "FREQ=MONTHLY;COUNT=3", dtstart: date(2021, time.January, 31, 9),
+			from: date(2021, time.January, 1, 0), to: date(2022, time.January, 1, 0),
+			expected: []time.Time{date(2021, time.January, 31, 9), date(2021, time.March, 31, 9), date(2021, time.May, 31, 9)},
+		},
+		{
+			name: "monthly last friday", rule: "FREQ=MONTHLY;BYDAY=-1FR", dtstart: date(2021, time.September, 24, 18),
+			from: date(2021, time.October, 1, 0), to: date(2022, time.January, 1, 0),
+			expected: []time.Time{
+				date(2021, time.October, 29, 18), date(2021, time.November, 26, 18), date(2021, time.December, 31, 18),
+			},
+		},
+		{
+			name: "monthly first monday", rule: "FREQ=MONTHLY;BYDAY=1MO;COUNT=3", dtstart: dtstart,
+			from: dtstart, to: dtstart.AddDate(1, 0, 0),
+			expected: []time.Time{dtstart, date(2021, time.October, 4, 10), date(2021, time.November, 1, 10)},
+		},
+		{
+			name: "yearly leap day", rule: "FREQ=YEARLY", dtstart: date(2020, time.February, 29, 12),
+			from: date(2021, time.January, 1, 0), to: date(2029, time.January, 1, 0),
+			expected: []time.Time{date(2024, time.February, 29, 12), date(2028, time.February, 29, 12)},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := Parse(tc.rule)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, rule.Between(tc.dtstart, tc.from, tc.to))
+		})
+	}
+}
+
+func TestLast(t *testing.T) {
+	dtstart := date(2021, time.September, 6, 10)
+
+	rule, err := Parse("FREQ=WEEKLY;BYDAY=MO,FR;COUNT=4")
+	require.NoError(t, err)
+	last, ok := rule.Last(dtstart)
+	require.True(t, ok)
+	require.Equal(t, date(2021, time.September, 17, 10), last)
+
+	rule, err = Parse("FREQ=DAILY;UNTIL=20210910T000000Z")
+	require.NoError(t, err)
+	last, ok = rule.Last(dtstart)
+	require.True(t, ok)
+	require.Equal(t, date(2021, time.September, 9, 10), last)
+
+	rule, err = Parse("FREQ=DAILY")
+	require.NoError(t, err)
+	_, ok = rule.Last(dtstart)
+	require.False(t, ok)
+
+	// 20000 понедельников не укладываются в maxPeriods дней: усечённая серия
+	// не выдаётся за законченную.
+	rule, err = Parse("FREQ=DAILY;BYDAY=MO;COUNT=20000")
+	require.NoError(t, err)
+	_, ok = rule.Last(dtstart)
+	require.False(t, ok)
+}
@@ -0,0 +1,54 @@
+// Package reqctx переносит через context.Context идентификаторы текущей операции:
+// ID запроса для корреляции логов, ID пользователя и его часовой пояс.
+package reqctx
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	userIDKey
+	timeZoneKey
+)
+
+// NewRequestID генерирует новый ID запроса.
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID возвращает ID запроса или пустую строку, если он не задан.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func WithUserID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, userIDKey, id)
+}
+
+// UserID возвращает ID пользователя или пустую строку, если он не задан.
+func UserID(ctx context.Context) string {
+	id, _ := ctx.Value(userIDKey).(string)
+	return id
+}
+
+// WithTimeZone запоминает IANA-имя часового пояса пользователя, в котором
+// по умолчанию считаются границы дня, недели и месяца.
+func WithTimeZone(ctx context.Context, zone string) context.Context {
+	return context.WithValue(ctx, timeZoneKey, zone)
+}
+
+// TimeZone возвращает часовой пояс пользователя или пустую строку, если он не задан.
+func TimeZone(ctx context.Context) string {
+	zone, _ := ctx.Value(timeZoneKey).(string)
+	return zone
+}
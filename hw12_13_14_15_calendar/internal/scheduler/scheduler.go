@@ -0,0 +1,187 @@
+// Package scheduler периодически ставит в очередь напоминания о событиях
+// и удаляет из хранилища давно прошедшие события и события из корзины.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+type Logger interface {
+	InfoContext(ctx context.Context, msg string, keyvals ...interface{})
+	ErrorContext(ctx context.Context, msg string, keyvals ...interface{})
+}
+
+type Storage interface {
+	ListNotifyDue(ctx context.Context, now time.Time) ([]storage.Event, error)
+	// MarkNotified запоминает начало повторения, о котором отправлено уведомление;
+	// нулевое время снимает отметку.
+	MarkNotified(ctx context.Context, id string, occurrence time.Time) error
+	DeleteEndedBefore(ctx context.Context, before time.Time) (int, error)
+	DeleteTrashedBefore(ctx context.Context, before time.Time) (int, error)
+}
+
+// Publisher отправляет сериализованное уведомление в очередь.
+type Publisher interface {
+	Publish(ctx context.Context, body []byte) error
+}
+
+// Settings - параметры планировщика, которые можно менять без перезапуска.
+type Settings struct {
+	// Interval - период сканирования хранилища.
+	Interval time.Duration
+	// Retention - сколько хранить закончившиеся события, 0 - не удалять.
+	Retention time.Duration
+	// TrashRetention - сколько хранить в корзине удалённые события, 0 - не удалять.
+	TrashRetention time.Duration
+}
+
+type Scheduler struct {
+	logger    Logger
+	storage   Storage
+	publisher Publisher
+
+	mu       sync.Mutex
+	settings Settings
+	// changed сигнализирует работающему Run об изменении настроек.
+	changed chan struct{}
+}
+
+func New(logger Logger, storage Storage, publisher Publisher, settings Settings) *Scheduler {
+	return &Scheduler{
+		logger:    logger,
+		storage:   storage,
+		publisher: publisher,
+		settings:  settings,
+		changed:   make(chan struct{}, 1),
+	}
+}
+
+// SetSettings применяет новые настройки; новый интервал начинает действовать сразу.
+func (s *Scheduler) SetSettings(settings Settings) {
+	s.mu.Lock()
+	s.settings = settings
+	s.mu.Unlock()
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) getSettings() Settings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings
+}
+
+// Run сканирует хранилище сразу и далее с заданным интервалом, пока не отменён ctx.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.getSettings().Interval)
+	defer ticker.Stop()
+
+	s.RunOnce(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.changed:
+			ticker.Reset(s.getSettings().Interval)
+		case <-ticker.C:
+			s.RunOnce(ctx, time.Now())
+		}
+	}
+}
+
+// RunOnce ставит в очередь наступившие напоминания и удаляет устаревшие события.
+// Каждый запуск получает свой ID запроса: он попадает в логи запуска и в уведомления,
+// чтобы по нему можно было найти и логи рассыльщика.
+func (s *Scheduler) RunOnce(ctx context.Context, now time.Time) {
+	ctx = reqctx.WithRequestID(ctx, reqctx.NewRequestID())
+	s.notify(ctx, now)
+	settings := s.getSettings()
+	if settings.Retention > 0 {
+		s.cleanup(ctx, now.Add(-settings.Retention))
+	}
+	if settings.TrashRetention > 0 {
+		s.emptyTrash(ctx, now.Add(-settings.TrashRetention))
+	}
+}
+
+// notify отмечает повторение события как уведомлённое до публикации, поэтому повторный запуск
+// не поставит то же напоминание в очередь ещё раз. Напоминание получает владелец и каждый
+// участник, принявший приглашение. Если не удалось опубликовать ни одного уведомления,
+// отметка снимается, и напоминание будет отправлено при следующем запуске; при частичной
+// неудаче отметка остаётся, чтобы не повторять уже доставленные уведомления.
+func (s *Scheduler) notify(ctx context.Context, now time.Time) {
+	events, err := s.storage.ListNotifyDue(ctx, now)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list events to notify", "err", err)
+		return
+	}
+
+	for _, event := range events {
+		if err := s.storage.MarkNotified(ctx, event.ID, event.Start); err != nil {
+			s.logger.ErrorContext(ctx, "failed to mark event notified", "event_id", event.ID, "err", err)
+			continue
+		}
+		if s.publish(ctx, event) > 0 {
+			continue
+		}
+		if err := s.storage.MarkNotified(ctx, event.ID, time.Time{}); err != nil {
+			s.logger.ErrorContext(ctx, "failed to unmark event notified", "event_id", event.ID, "err", err)
+		}
+	}
+}
+
+// publish ставит в очередь уведомления всем получателям события и возвращает число
+// опубликованных.
+func (s *Scheduler) publish(ctx context.Context, event storage.Event) int {
+	published := 0
+	for _, userID := range event.Recipients() {
+		notification := storage.NewNotification(event)
+		notification.UserID = userID
+		notification.RequestID = reqctx.RequestID(ctx)
+		body, err := json.Marshal(notification)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to encode notification", "event_id", event.ID, "err", err)
+			continue
+		}
+		if err := s.publisher.Publish(ctx, body); err != nil {
+			s.logger.ErrorContext(ctx, "failed to publish notification",
+				"event_id", event.ID, "user_id", userID, "err", err)
+			continue
+		}
+		published++
+		s.logger.InfoContext(ctx, "notification enqueued",
+			"event_id", event.ID, "user_id", userID, "start", event.Start)
+	}
+	return published
+}
+
+func (s *Scheduler) cleanup(ctx context.Context, before time.Time) {
+	deleted, err := s.storage.DeleteEndedBefore(ctx, before)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to delete old events", "err", err)
+		return
+	}
+	if deleted > 0 {
+		s.logger.InfoContext(ctx, "old events deleted", "count", deleted, "before", before)
+	}
+}
+
+func (s *Scheduler) emptyTrash(ctx context.Context, before time.Time) {
+	deleted, err := s.storage.DeleteTrashedBefore(ctx, before)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to empty trash", "err", err)
+		return
+	}
+	if deleted > 0 {
+		s.logger.InfoContext(ctx, "trashed events deleted", "count", deleted, "before", before)
+	}
+}
@@ -0,0 +1,184 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/queue"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+type publisher struct {
+	fail     bool
+	messages []storage.Notification
+	// requestIDs - ID запросов опубликованных уведомлений; в messages они обнулены.
+	requestIDs []string
+}
+
+func (p *publisher) Publish(ctx context.Context, body []byte) error {
+	if p.fail {
+		return errors.New("queue is unavailable")
+	}
+	var n storage.Notification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return err
+	}
+	p.requestIDs = append(p.requestIDs, n.RequestID)
+	n.RequestID = ""
+	p.messages = append(p.messages, n)
+	return nil
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, time.September, 6, 9, 50, 0, 0, time.UTC)
+	start := time.Date(2021, time.September, 6, 10, 0, 0, 0, time.UTC)
+
+	events := memorystorage.New()
+	require.NoError(t, events.Create(ctx, storage.Event{
+		ID: "standup", Title: "standup", Start: start, End: start.Add(15 * time.Minute),
+		UserID: "user", NotifyBefore: 15 * time.Minute,
+	}))
+	require.NoError(t, events.Create(ctx, storage.Event{
+		ID: "old", Title: "old", Start: start.AddDate(-2, 0, 0), End: start.AddDate(-2, 0, 0).Add(time.Hour),
+		UserID: "user",
+	}))
+
+	pub := &publisher{fail: true}
+	logg := logger.New("error", logger.WithOutput(io.Discard))
+	s := New(logg, events, pub, Settings{Interval: time.Minute, Retention: 365 * 24 * time.Hour})
+
+	// Неудачная публикация не теряет напоминание.
+	s.RunOnce(ctx, now)
+	require.Empty(t, pub.messages)
+	_, err := events.Get(ctx, "old")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	pub.fail = false
+	s.RunOnce(ctx, now)
+	s.RunOnce(ctx, now.Add(time.Minute))
+	require.Equal(t, []storage.Notification{
+		{EventID: "standup", Title: "standup", Date: start, UserID: "user"},
+	}, pub.messages)
+}
+
+func TestSchedulerRecurring(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2021, time.September, 6, 10, 0, 0, 0, time.UTC)
+
+	events := memorystorage.New()
+	require.NoError(t, events.Create(ctx, storage.Event{
+		ID: "standup", Title: "standup", Start: start, End: start.Add(15 * time.Minute),
+		UserID: "user", NotifyBefore: 15 * time.Minute, RRule: "FREQ=DAILY;COUNT=2",
+	}))
+
+	pub := &publisher{}
+	logg := logger.New("error", logger.WithOutput(io.Discard))
+	s := New(logg, events, pub, Settings{Interval: time.Minute})
+
+	for _, now := range []time.Time{start.Add(-10 * time.Minute), start, start.AddDate(0, 0, 1).Add(-time.Minute)} {
+		s.RunOnce(ctx, now)
+		s.RunOnce(ctx, now)
+	}
+	require.Equal(t, []storage.Notification{
+		{EventID: "standup", Title: "standup", Date: start, UserID: "user"},
+		{EventID: "standup", Title: "standup", Date: start.AddDate(0, 0, 1), UserID: "user"},
+	}, pub.messages)
+	// У разных запусков ID запросов разные.
+	require.NotEqual(t, pub.requestIDs[0], pub.requestIDs[1])
+}
+
+func TestSchedulerAttendees(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2021, time.September, 6, 10, 0, 0, 0, time.UTC)
+
+	events := memorystorage.New()
+	require.NoError(t, events.Create(ctx, storage.Event{
+		ID: "review", Title: "review", Start: start, End: start.Add(time.Hour),
+		UserID: "owner", NotifyBefore: 15 * time.Minute,
+		Attendees: []storage.Attendee{
+			{UserID: "alice", Status: storage.StatusAccepted},
+			{UserID: "bob", Status: storage.StatusDeclined},
+			{UserID: "carol", Status: storage.StatusPending},
+		},
+	}))
+
+	pub := &publisher{}
+	logg := logger.New("error", logger.WithOutput(io.Discard))
+	s := New(logg, events, pub, Settings{Interval: time.Minute})
+
+	s.RunOnce(ctx, start.Add(-10*time.Minute))
+	s.RunOnce(ctx, start.Add(-5*time.Minute))
+	require.Equal(t, []storage.Notification{
+		{EventID: "review", Title: "review", Date: start, UserID: "owner"},
+		{EventID: "review", Title: "review", Date: start, UserID: "alice"},
+	}, pub.messages)
+	// Уведомления одного запуска несут общий ID запроса.
+	require.NotEmpty(t, pub.requestIDs[0])
+	require.Equal(t, pub.requestIDs[0], pub.requestIDs[1])
+}
+
+func TestSchedulerTrash(t *testing.T) {
+	ctx := context.Background()
+	start := time.Now().Add(time.Hour)
+
+	events := memorystorage.New()
+	require.NoError(t, events.Create(ctx, storage.Event{
+		ID: "1", Title: "t", Start: start, End: start.Add(time.Hour), UserID: "user",
+	}))
+	require.NoError(t, events.Delete(ctx, "1", 0))
+
+	logg := logger.New("error", logger.WithOutput(io.Discard))
+	s := New(logg, events, &publisher{}, Settings{Interval: time.Minute, TrashRetention: time.Hour})
+
+	s.RunOnce(ctx, time.Now())
+	trash, err := events.ListDeleted(ctx, "user")
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+
+	s.RunOnce(ctx, time.Now().Add(2*time.Hour))
+	trash, err = events.ListDeleted(ctx, "user")
+	require.NoError(t, err)
+	require.Empty(t, trash)
+}
+
+func TestSchedulerRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Now().Add(time.Minute)
+	events := memorystorage.New()
+	require.NoError(t, events.Create(ctx, storage.Event{
+		ID: "1", Title: "soon", Start: start, End: start.Add(time.Hour), UserID: "user", NotifyBefore: time.Hour,
+	}))
+
+	logg := logger.New("error", logger.WithOutput(io.Discard))
+	q := queue.NewMemory(logg, queue.MemoryConfig{})
+	s := New(logg, events, q, Settings{Interval: time.Hour})
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	s.SetSettings(Settings{Interval: time.Millisecond})
+
+	consumeCtx, stop := context.WithTimeout(ctx, time.Second)
+	defer stop()
+	var received storage.Notification
+	require.NoError(t, q.Consume(consumeCtx, func(ctx context.Context, body []byte) error {
+		stop()
+		return json.Unmarshal(body, &received)
+	}))
+	require.Equal(t, "1", received.EventID)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,49 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+// WriterNotifier выводит уведомления текстом в io.Writer, например в STDOUT.
+type WriterNotifier struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterNotifier(w io.Writer) *WriterNotifier {
+	return &WriterNotifier{w: w}
+}
+
+func (n *WriterNotifier) Notify(ctx context.Context, notification storage.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, err := fmt.Fprintf(n.w, "reminder for %s: %q at %s (event %s)\n",
+		notification.UserID, notification.Title, notification.Date.Format(time.RFC3339), notification.EventID)
+	return err
+}
+
+// LogNotifier пишет уведомления в лог.
+type LogNotifier struct {
+	logger Logger
+}
+
+func NewLogNotifier(logger Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, notification storage.Notification) error {
+	n.logger.InfoContext(ctx, "reminder",
+		"event_id", notification.EventID,
+		"title", notification.Title,
+		"date", notification.Date.Format(time.RFC3339),
+		"user_id", notification.UserID,
+	)
+	return nil
+}
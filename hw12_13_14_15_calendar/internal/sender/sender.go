@@ -0,0 +1,98 @@
+// Package sender доставляет пользователям уведомления, полученные из очереди.
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/queue"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+type Logger interface {
+	InfoContext(ctx context.Context, msg string, keyvals ...interface{})
+	WarnContext(ctx context.Context, msg string, keyvals ...interface{})
+	ErrorContext(ctx context.Context, msg string, keyvals ...interface{})
+}
+
+// Notifier доставляет уведомление пользователю.
+type Notifier interface {
+	Notify(ctx context.Context, notification storage.Notification) error
+}
+
+// Publisher отправляет сериализованный статус доставки в очередь.
+type Publisher interface {
+	Publish(ctx context.Context, body []byte) error
+}
+
+type Sender struct {
+	logger   Logger
+	notifier Notifier
+	// status получает отчёт о каждой попытке доставки, может быть nil.
+	status Publisher
+	now    func() time.Time
+}
+
+func New(logger Logger, notifier Notifier, status Publisher) *Sender {
+	return &Sender{
+		logger:   logger,
+		notifier: notifier,
+		status:   status,
+		now:      time.Now,
+	}
+}
+
+// Handle доставляет уведомление из тела сообщения. Ошибка означает, что сообщение
+// не доставлено и не должно подтверждаться; нераспознанные сообщения отбрасываются
+// с queue.ErrDiscard. Логи обработки несут ID запроса из уведомления, а если его
+// нет - новый.
+func (s *Sender) Handle(ctx context.Context, body []byte) error {
+	var notification storage.Notification
+	err := json.Unmarshal(body, &notification)
+	if notification.RequestID == "" {
+		notification.RequestID = reqctx.NewRequestID()
+	}
+	ctx = reqctx.WithRequestID(ctx, notification.RequestID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to decode notification", "err", err)
+		return fmt.Errorf("%w: %v", queue.ErrDiscard, err)
+	}
+
+	status := storage.NotificationStatus{
+		EventID:   notification.EventID,
+		UserID:    notification.UserID,
+		Status:    storage.NotificationSent,
+		RequestID: notification.RequestID,
+	}
+	err = s.notifier.Notify(ctx, notification)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to send notification",
+			"event_id", notification.EventID, "user_id", notification.UserID, "err", err)
+		status.Status = storage.NotificationFailed
+		status.Error = err.Error()
+	} else {
+		s.logger.InfoContext(ctx, "notification sent",
+			"event_id", notification.EventID, "user_id", notification.UserID)
+	}
+	s.report(ctx, status)
+	return err
+}
+
+// report публикует статус доставки. Ошибка публикации не влияет на подтверждение
+// сообщения: уведомление уже доставлено или будет доставлено повторно.
+func (s *Sender) report(ctx context.Context, status storage.NotificationStatus) {
+	if s.status == nil {
+		return
+	}
+	status.Time = s.now()
+	body, err := json.Marshal(status)
+	if err == nil {
+		err = s.status.Publish(ctx, body)
+	}
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to report notification status", "event_id", status.EventID, "err", err)
+	}
+}
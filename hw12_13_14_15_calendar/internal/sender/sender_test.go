@@ -0,0 +1,102 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/queue"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type statusRecorder []storage.NotificationStatus
+
+func (r *statusRecorder) Publish(ctx context.Context, body []byte) error {
+	var status storage.NotificationStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return err
+	}
+	*r = append(*r, status)
+	return nil
+}
+
+type notifierFunc func(ctx context.Context, notification storage.Notification) error
+
+func (f notifierFunc) Notify(ctx context.Context, notification storage.Notification) error {
+	return f(ctx, notification)
+}
+
+func TestSender(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, time.September, 6, 9, 45, 0, 0, time.UTC)
+	logg := logger.New("error", logger.WithOutput(io.Discard))
+	body, err := json.Marshal(storage.Notification{
+		EventID:   "1",
+		Title:     "standup",
+		Date:      time.Date(2021, time.September, 6, 10, 0, 0, 0, time.UTC),
+		UserID:    "user",
+		RequestID: "req-1",
+	})
+	require.NoError(t, err)
+
+	t.Run("sent", func(t *testing.T) {
+		var out bytes.Buffer
+		var statuses statusRecorder
+		s := New(logg, NewWriterNotifier(&out), &statuses)
+		s.now = func() time.Time { return now }
+
+		require.NoError(t, s.Handle(ctx, body))
+		require.Equal(t, "reminder for user: \"standup\" at 2021-09-06T10:00:00Z (event 1)\n", out.String())
+		require.Equal(t, statusRecorder{
+			{EventID: "1", UserID: "user", Status: storage.NotificationSent, Time: now, RequestID: "req-1"},
+		}, statuses)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		var statuses statusRecorder
+		s := New(logg, notifierFunc(func(ctx context.Context, _ storage.Notification) error {
+			require.Equal(t, "req-1", reqctx.RequestID(ctx))
+			return errors.New("smtp is down")
+		}), &statuses)
+		s.now = func() time.Time { return now }
+
+		err := s.Handle(ctx, body)
+		require.Error(t, err)
+		require.False(t, errors.Is(err, queue.ErrDiscard))
+		require.Equal(t, statusRecorder{
+			{
+				EventID: "1", UserID: "user", Status: storage.NotificationFailed, Error: "smtp is down", Time: now,
+				RequestID: "req-1",
+			},
+		}, statuses)
+	})
+
+	t.Run("without request id", func(t *testing.T) {
+		var statuses statusRecorder
+		var requestID string
+		s := New(logg, notifierFunc(func(ctx context.Context, _ storage.Notification) error {
+			requestID = reqctx.RequestID(ctx)
+			return nil
+		}), &statuses)
+
+		require.NoError(t, s.Handle(ctx, []byte(`{"eventId":"1","userId":"user"}`)))
+		require.NotEmpty(t, requestID)
+		require.Len(t, statuses, 1)
+		require.Equal(t, requestID, statuses[0].RequestID)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		var statuses statusRecorder
+		s := New(logg, NewLogNotifier(logg), &statuses)
+
+		require.ErrorIs(t, s.Handle(ctx, []byte("{")), queue.ErrDiscard)
+		require.Empty(t, statuses)
+	})
+}
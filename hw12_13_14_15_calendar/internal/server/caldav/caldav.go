@@ -0,0 +1,470 @@
+// Package caldav реализует минимальный сервер CalDAV (RFC 4791) поверх приложения,
+// чтобы Thunderbird, Apple Calendar и другие клиенты синхронизировали календарь
+// пользователя напрямую.
+//
+// Каждому пользователю доступен один календарь:
+//
+//	/caldav/                 - принципал и домашний набор календарей;
+//	/caldav/calendar/        - коллекция событий (PROPFIND, REPORT);
+//	/caldav/calendar/UID.ics - событие (GET, PUT, DELETE).
+//
+// Ресурс собственного события называется по его UID, который уникален среди событий
+// владельца; ресурс события, на которое пользователь приглашён, - по ID события.
+//
+// Пользователь определяется так же, как для остального HTTP API: по заголовку X-User-ID,
+// который выставляет проверяющий учётные данные прокси перед сервисом. Заголовок
+// Authorization не используется: пароль в нём проверять нечем.
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/ical"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+// Prefix - путь, по которому HTTP-сервер монтирует обработчик.
+const Prefix = "/caldav/"
+
+const (
+	methodPropfind = "PROPFIND"
+	methodReport   = "REPORT"
+)
+
+const (
+	calendarPath = Prefix + "calendar/"
+	eventExt     = ".ics"
+	// maxEventSize ограничивает размер тела PUT.
+	maxEventSize = 1 << 20
+	allowMethods = "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT"
+)
+
+// allFrom и allTo заменяют отсутствующую границу фильтра по времени.
+var (
+	allFrom = time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+	allTo   = time.Date(2200, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+type Logger interface {
+	ErrorContext(ctx context.Context, msg string, keyvals ...interface{})
+}
+
+type Application interface {
+	CreateEvent(ctx context.Context, event storage.Event) (storage.Event, error)
+	UpdateEvent(ctx context.Context, id string, event storage.Event) (storage.Event, error)
+	DeleteEvent(ctx context.Context, id string, version int64) error
+	GetEvent(ctx context.Context, id string) (storage.Event, error)
+	GetEventByUID(ctx context.Context, uid string) (storage.Event, error)
+	ExportEvents(ctx context.Context, from, to time.Time) ([]storage.Event, error)
+	ListOwnEvents(ctx context.Context) ([]storage.Event, error)
+}
+
+type Handler struct {
+	logger Logger
+	app    Application
+}
+
+func NewHandler(logger Logger, app Application) *Handler {
+	return &Handler{logger: logger, app: app}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if reqctx.UserID(r.Context()) == "" {
+		http.Error(w, app.ErrUserRequired.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("DAV", "1, 3, calendar-access")
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Allow", allowMethods)
+		return
+	}
+
+	switch path := r.URL.Path; {
+	case path == Prefix:
+		h.serveRoot(w, r)
+	case path == calendarPath || path+"/" == calendarPath:
+		h.serveCalendar(w, r)
+	case strings.HasPrefix(path, calendarPath) && strings.HasSuffix(path, eventExt):
+		name := strings.TrimSuffix(strings.TrimPrefix(path, calendarPath), eventExt)
+		h.serveEvent(w, r, name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) serveRoot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != methodPropfind {
+		methodNotAllowed(w)
+		return
+	}
+	req, ok := readPropfind(w, r)
+	if !ok {
+		return
+	}
+
+	responses := []response{rootResource().response(req)}
+	if depth(r) > 0 {
+		events, err := h.app.ListOwnEvents(r.Context())
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		responses = append(responses, calendarResource(events).response(req))
+	}
+	writeMultistatus(w, responses)
+}
+
+func (h *Handler) serveCalendar(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case methodPropfind:
+		req, ok := readPropfind(w, r)
+		if !ok {
+			return
+		}
+		events, err := h.app.ListOwnEvents(r.Context())
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		responses := []response{calendarResource(events).response(req)}
+		if depth(r) > 0 {
+			for _, event := range events {
+				responses = append(responses, eventResource(r.Context(), event).response(req))
+			}
+		}
+		writeMultistatus(w, responses)
+	case methodReport:
+		h.report(w, r)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+// report отвечает на calendar-query (события за период) и calendar-multiget
+// (события по списку адресов).
+func (h *Handler) report(w http.ResponseWriter, r *http.Request) {
+	var req reportRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "malformed report: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	props := req.Prop.request()
+
+	var responses []response
+	switch req.XMLName {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		from, to, ranged, err := req.period()
+		if err != nil {
+			http.Error(w, "malformed time-range: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Без фильтра по времени клиент синхронизирует весь календарь, и разворачивать
+		// серии, чтобы найти пересекающиеся с 1970-2200 годами, незачем.
+		var events []storage.Event
+		if ranged {
+			events, err = h.app.ExportEvents(r.Context(), from, to)
+		} else {
+			events, err = h.app.ListOwnEvents(r.Context())
+		}
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		for _, event := range events {
+			responses = append(responses, eventResource(r.Context(), event).response(props))
+		}
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		for _, href := range req.Hrefs {
+			event, err := h.lookup(r.Context(), resourceName(href))
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				responses = append(responses, response{Href: href, Status: statusLine(http.StatusNotFound)})
+			case err != nil:
+				h.writeError(w, r, err)
+				return
+			default:
+				responses = append(responses, eventResource(r.Context(), event).response(props))
+			}
+		}
+	default:
+		http.Error(w, "unsupported report "+req.XMLName.Local, http.StatusForbidden)
+		return
+	}
+	writeMultistatus(w, responses)
+}
+
+func (h *Handler) serveEvent(w http.ResponseWriter, r *http.Request, name string) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		event, err := h.lookup(r.Context(), name)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", ical.ContentType)
+		w.Header().Set("ETag", etag(event))
+		if r.Method == http.MethodGet {
+			w.Write(encode(event))
+		}
+	case http.MethodPut:
+		h.put(w, r, name)
+	case http.MethodDelete:
+		current, version, ok := h.checkPreconditions(w, r, name)
+		if !ok {
+			return
+		}
+		if err := h.app.DeleteEvent(r.Context(), current.ID, version); err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case methodPropfind:
+		req, ok := readPropfind(w, r)
+		if !ok {
+			return
+		}
+		event, err := h.lookup(r.Context(), name)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		writeMultistatus(w, []response{eventResource(r.Context(), event).response(req)})
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+// put создаёт или заменяет событие. Ресурс должен содержать ровно один VEVENT:
+// изменённые отдельные повторения серий (RECURRENCE-ID) не поддерживаются.
+// Новое событие получает UID, равный имени ресурса, и собственный ID.
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, name string) {
+	events, err := ical.Decode(io.LimitReader(r.Body, maxEventSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(events) != 1 {
+		http.Error(w, "resource must contain exactly one VEVENT", http.StatusForbidden)
+		return
+	}
+	current, version, ok := h.checkPreconditions(w, r, name)
+	if !ok {
+		return
+	}
+
+	event := events[0]
+	status := http.StatusNoContent
+	if current.ID == "" {
+		event.UID = name
+		event, err = h.app.CreateEvent(r.Context(), event)
+		status = http.StatusCreated
+	} else {
+		// Участники в iCalendar не передаются, поэтому приглашения сохраняются.
+		event.Attendees = current.Attendees
+		event.Version = version
+		event, err = h.app.UpdateEvent(r.Context(), current.ID, event)
+	}
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(event))
+	w.WriteHeader(status)
+}
+
+// checkPreconditions проверяет If-Match и If-None-Match: * против текущей версии
+// события и отвечает 412, если клиент изменяет устаревшую версию. Как и в остальных
+// API, изменение и удаление требуют If-Match, а создание - If-None-Match: *; без них
+// ответ - 428. Возвращаются текущее событие ресурса (пустое, если его нет) и версия
+// из If-Match, чтобы хранилище проверило её ещё раз при записи; 0 - любая версия.
+func (h *Handler) checkPreconditions(
+	w http.ResponseWriter, r *http.Request, name string,
+) (storage.Event, int64, bool) {
+	ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	if ifMatch == "" && (ifNoneMatch != "*" || r.Method != http.MethodPut) {
+		http.Error(w, "event version is required: set If-Match or, to create, If-None-Match: *",
+			http.StatusPreconditionRequired)
+		return storage.Event{}, 0, false
+	}
+
+	current, err := h.lookup(r.Context(), name)
+	exists := err == nil
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		h.writeError(w, r, err)
+		return storage.Event{}, 0, false
+	}
+
+	switch {
+	case ifNoneMatch == "*" && exists,
+		ifMatch == "*" && !exists,
+		ifMatch != "" && ifMatch != "*" && (!exists || ifMatch != etag(current)):
+		http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+		return storage.Event{}, 0, false
+	case ifMatch != "" && ifMatch != "*":
+		return current, current.Version, true
+	}
+	return current, 0, true
+}
+
+// lookup возвращает событие ресурса name: собственное событие с этим UID или событие
+// с этим ID, на которое пользователь приглашён.
+func (h *Handler) lookup(ctx context.Context, name string) (storage.Event, error) {
+	event, err := h.app.GetEventByUID(ctx, name)
+	if !errors.Is(err, storage.ErrNotFound) {
+		return event, err
+	}
+	event, err = h.app.GetEvent(ctx, name)
+	if err == nil && event.UserID == reqctx.UserID(ctx) {
+		// Собственное событие адресуется только по UID.
+		return storage.Event{}, storage.ErrNotFound
+	}
+	return event, err
+}
+
+// writeError отображает ошибки приложения в коды HTTP; нарушения предусловий CalDAV
+// (некорректное событие) по RFC 4791 - это 403.
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, app.ErrUserRequired):
+		code = http.StatusUnauthorized
+	case errors.Is(err, storage.ErrNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, app.ErrForbidden):
+		code = http.StatusForbidden
+	case errors.Is(err, storage.ErrDateBusy), errors.Is(err, storage.ErrEventExists):
+		code = http.StatusConflict
+	case errors.Is(err, storage.ErrVersionConflict):
+		code = http.StatusPreconditionFailed
+	case errors.Is(err, storage.ErrInvalidEvent):
+		code = http.StatusForbidden
+	default:
+		h.logger.ErrorContext(r.Context(), "caldav request failed", "method", r.Method, "path", r.URL.Path, "err", err)
+		http.Error(w, "internal error", code)
+		return
+	}
+	http.Error(w, err.Error(), code)
+}
+
+func readPropfind(w http.ResponseWriter, r *http.Request) (propRequest, bool) {
+	var req propfindRequest
+	err := xml.NewDecoder(r.Body).Decode(&req)
+	switch {
+	case errors.Is(err, io.EOF):
+		// Пустое тело PROPFIND означает allprop.
+		return propRequest{all: true}, true
+	case err != nil:
+		http.Error(w, "malformed propfind: "+err.Error(), http.StatusBadRequest)
+		return propRequest{}, false
+	case req.AllProp != nil:
+		return propRequest{all: true}, true
+	}
+	return req.Prop.request(), true
+}
+
+// depth возвращает глубину PROPFIND: 0 или 1; infinity обрабатывается как 1.
+func depth(r *http.Request) int {
+	if r.Header.Get("Depth") == "0" {
+		return 0
+	}
+	return 1
+}
+
+func methodNotAllowed(w http.ResponseWriter) {
+	w.Header().Set("Allow", allowMethods)
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+func rootResource() resource {
+	return resource{
+		href: Prefix,
+		props: map[xml.Name]string{
+			propResourceType:     `<collection xmlns="DAV:"/><principal xmlns="DAV:"/>`,
+			propDisplayName:      "calendar",
+			propCurrentPrincipal: hrefXML(Prefix),
+			propPrincipalURL:     hrefXML(Prefix),
+			propCalendarHomeSet:  hrefXML(Prefix),
+		},
+	}
+}
+
+func calendarResource(events []storage.Event) resource {
+	return resource{
+		href: calendarPath,
+		props: map[xml.Name]string{
+			propResourceType:       `<collection xmlns="DAV:"/><calendar xmlns="` + nsCalDAV + `"/>`,
+			propDisplayName:        "calendar",
+			propCurrentPrincipal:   hrefXML(Prefix),
+			propSupportedComponent: `<comp xmlns="` + nsCalDAV + `" name="VEVENT"/>`,
+			propGetCTag:            escape(ctag(events)),
+		},
+	}
+}
+
+func eventResource(ctx context.Context, event storage.Event) resource {
+	name := event.ID
+	if event.UserID == reqctx.UserID(ctx) {
+		name = event.UID
+	}
+	return resource{
+		href: eventHref(name),
+		props: map[xml.Name]string{
+			propResourceType:   "",
+			propGetETag:        escape(etag(event)),
+			propGetContentType: escape(ical.ContentType + "; component=VEVENT"),
+			propCalendarData:   escape(string(encode(event))),
+		},
+	}
+}
+
+func eventHref(name string) string {
+	return calendarPath + url.PathEscape(name) + eventExt
+}
+
+// resourceName извлекает имя ресурса события из его адреса; href может быть полным URL.
+func resourceName(href string) string {
+	if u, err := url.Parse(href); err == nil {
+		href = u.Path
+	}
+	name := strings.TrimSuffix(href[strings.LastIndex(href, "/")+1:], eventExt)
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		return unescaped
+	}
+	return name
+}
+
+func encode(event storage.Event) []byte {
+	var buf bytes.Buffer
+	ical.Encode(&buf, []storage.Event{event}, time.Now())
+	return buf.Bytes()
+}
+
+// etag - версия события, поэтому меняется при любом изменении.
+func etag(event storage.Event) string {
+	return storage.ETag(event.Version)
+}
+
+// ctag меняется при изменении любого события календаря.
+func ctag(events []storage.Event) string {
+	tags := make([]string, 0, len(events))
+	for _, event := range events {
+		tags = append(tags, event.ID+etag(event))
+	}
+	sort.Strings(tags)
+	sum := sha256.Sum256([]byte(strings.Join(tags, ",")))
+	return hex.EncodeToString(sum[:])
+}
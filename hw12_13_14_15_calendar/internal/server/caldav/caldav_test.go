@@ -0,0 +1,235 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+	memorystorage "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+const standup = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:standup\r\n" +
+	"DTSTART:20210906T100000Z\r\nDTEND:20210906T101500Z\r\nRRULE:FREQ=DAILY;COUNT=5\r\n" +
+	"SUMMARY:Standup\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+func newHandler() *Handler {
+	logg := logger.New("error", logger.WithOutput(io.Discard))
+	return NewHandler(logg, app.New(logg, memorystorage.New()))
+}
+
+func do(h *Handler, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	return doAs(h, "user", method, target, body, headers...)
+}
+
+func doAs(h *Handler, userID, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r = r.WithContext(reqctx.WithUserID(r.Context(), userID))
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// parse разбирает multistatus в отображение href -> свойство -> значение.
+func parse(t *testing.T, w *httptest.ResponseRecorder) map[string]map[string]string {
+	t.Helper()
+	require.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+
+	var ms struct {
+		Responses []struct {
+			Href      string `xml:"href"`
+			Propstats []struct {
+				Props struct {
+					Values []struct {
+						XMLName xml.Name
+						Inner   string `xml:",innerxml"`
+						Text    string `xml:",chardata"`
+					} `xml:",any"`
+				} `xml:"prop"`
+				Status string `xml:"status"`
+			} `xml:"propstat"`
+		} `xml:"response"`
+	}
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &ms))
+
+	result := make(map[string]map[string]string)
+	for _, resp := range ms.Responses {
+		props := make(map[string]string)
+		for _, ps := range resp.Propstats {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			for _, v := range ps.Props.Values {
+				value := v.Text
+				if strings.TrimSpace(value) == "" {
+					value = v.Inner
+				}
+				props[v.XMLName.Local] = value
+			}
+		}
+		result[resp.Href] = props
+	}
+	return result
+}
+
+func TestCalDAV(t *testing.T) {
+	h := newHandler()
+
+	w := do(h, http.MethodPut, "/caldav/calendar/standup.ics", standup, "If-None-Match", "*")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	// Повторное создание с If-None-Match: * - конфликт версий.
+	w = do(h, http.MethodPut, "/caldav/calendar/standup.ics", standup, "If-None-Match", "*")
+	require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w = do(h, http.MethodGet, "/caldav/calendar/standup.ics", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, etag, w.Header().Get("ETag"))
+	require.Contains(t, w.Body.String(), "RRULE:FREQ=DAILY;COUNT=5")
+
+	t.Run("propfind", func(t *testing.T) {
+		w := do(h, methodPropfind, "/caldav/", `<?xml version="1.0"?>
+			<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+				<d:prop><d:current-user-principal/><c:calendar-home-set/><d:quota-used-bytes/></d:prop>
+			</d:propfind>`, "Depth", "0")
+		resources := parse(t, w)
+		require.Len(t, resources, 1)
+		require.Contains(t, resources["/caldav/"]["calendar-home-set"], "/caldav/")
+		require.NotContains(t, resources["/caldav/"], "quota-used-bytes")
+
+		w = do(h, methodPropfind, "/caldav/calendar/", "", "Depth", "1")
+		resources = parse(t, w)
+		require.Len(t, resources, 2)
+		require.Contains(t, resources["/caldav/calendar/"]["resourcetype"], "calendar")
+		require.NotEmpty(t, resources["/caldav/calendar/"]["getctag"])
+		require.Equal(t, etag, resources["/caldav/calendar/standup.ics"]["getetag"])
+		require.NotContains(t, resources["/caldav/calendar/standup.ics"], "calendar-data")
+	})
+
+	t.Run("calendar-query", func(t *testing.T) {
+		query := func(start, end string) map[string]map[string]string {
+			return parse(t, do(h, methodReport, "/caldav/calendar/", `<?xml version="1.0"?>
+				<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+					<d:prop><d:getetag/><c:calendar-data/></d:prop>
+					<c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT">
+						<c:time-range start="`+start+`" end="`+end+`"/>
+					</c:comp-filter></c:comp-filter></c:filter>
+				</c:calendar-query>`, "Depth", "1"))
+		}
+
+		resources := query("20210908T000000Z", "20210909T000000Z")
+		require.Len(t, resources, 1)
+		require.Contains(t, resources["/caldav/calendar/standup.ics"]["calendar-data"], "UID:standup")
+		require.Empty(t, query("20211001T000000Z", "20211002T000000Z"))
+
+		// Без фильтра по времени возвращаются все события, серия - один раз.
+		resources = parse(t, do(h, methodReport, "/caldav/calendar/", `<?xml version="1.0"?>
+			<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+				<d:prop><d:getetag/></d:prop>
+				<c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT"/></c:comp-filter></c:filter>
+			</c:calendar-query>`, "Depth", "1"))
+		require.Len(t, resources, 1)
+		require.Equal(t, etag, resources["/caldav/calendar/standup.ics"]["getetag"])
+	})
+
+	t.Run("calendar-multiget", func(t *testing.T) {
+		w := do(h, methodReport, "/caldav/calendar/", `<?xml version="1.0"?>
+			<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+				<d:prop><d:getetag/></d:prop>
+				<d:href>/caldav/calendar/standup.ics</d:href>
+				<d:href>/caldav/calendar/missing.ics</d:href>
+			</c:calendar-multiget>`)
+		resources := parse(t, w)
+		require.Equal(t, etag, resources["/caldav/calendar/standup.ics"]["getetag"])
+		require.Contains(t, w.Body.String(), "404 Not Found")
+	})
+
+	// Изменение со старым ETag отклоняется, с текущим - применяется.
+	moved := strings.Replace(standup, "T100000Z", "T110000Z", 1)
+	moved = strings.Replace(moved, "T101500Z", "T111500Z", 1)
+	w = do(h, http.MethodPut, "/caldav/calendar/standup.ics", moved, "If-Match", `"stale"`)
+	require.Equal(t, http.StatusPreconditionFailed, w.Code)
+	// Без If-Match событие не перезаписывается и не удаляется.
+	require.Equal(t, http.StatusPreconditionRequired, do(h, http.MethodPut, "/caldav/calendar/standup.ics", moved).Code)
+	require.Equal(t, http.StatusPreconditionRequired, do(h, http.MethodDelete, "/caldav/calendar/standup.ics", "").Code)
+
+	w = do(h, http.MethodPut, "/caldav/calendar/standup.ics", moved, "If-Match", etag)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	current := w.Header().Get("ETag")
+	require.NotEqual(t, etag, current)
+
+	// Пересечение с существующим событием - конфликт.
+	busy := strings.Replace(moved, "UID:standup", "UID:busy", 1)
+	w = do(h, http.MethodPut, "/caldav/calendar/busy.ics", busy, "If-None-Match", "*")
+	require.Equal(t, http.StatusConflict, w.Code)
+	// Создание без If-None-Match: * тоже требует предусловия.
+	require.Equal(t, http.StatusPreconditionRequired, do(h, http.MethodPut, "/caldav/calendar/new.ics", busy).Code)
+
+	w = do(h, http.MethodDelete, "/caldav/calendar/standup.ics", "", "If-Match", etag)
+	require.Equal(t, http.StatusPreconditionFailed, w.Code)
+	w = do(h, http.MethodDelete, "/caldav/calendar/standup.ics", "", "If-Match", current)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, http.StatusNotFound, do(h, http.MethodGet, "/caldav/calendar/standup.ics", "").Code)
+}
+
+func TestCalDAVSameUID(t *testing.T) {
+	h := newHandler()
+
+	// Одно и то же приглашение в календарях разных пользователей - разные события.
+	w := doAs(h, "alice", http.MethodPut, "/caldav/calendar/standup.ics", standup, "If-None-Match", "*")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = doAs(h, "bob", http.MethodPut, "/caldav/calendar/standup.ics", standup, "If-None-Match", "*")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	moved := strings.Replace(standup, "SUMMARY:Standup", "SUMMARY:Moved", 1)
+	w = doAs(h, "bob", http.MethodPut, "/caldav/calendar/standup.ics", moved, "If-Match", w.Header().Get("ETag"))
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	require.Contains(t, doAs(h, "alice", http.MethodGet, "/caldav/calendar/standup.ics", "").Body.String(),
+		"SUMMARY:Standup")
+	require.Contains(t, doAs(h, "bob", http.MethodGet, "/caldav/calendar/standup.ics", "").Body.String(),
+		"SUMMARY:Moved")
+
+	// Удалённый ресурс можно создать заново с тем же именем.
+	w = doAs(h, "alice", http.MethodDelete, "/caldav/calendar/standup.ics", "", "If-Match", "*")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	w = doAs(h, "alice", http.MethodPut, "/caldav/calendar/standup.ics", standup, "If-None-Match", "*")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+}
+
+func TestCalDAVErrors(t *testing.T) {
+	h := newHandler()
+
+	r := httptest.NewRequest(methodPropfind, "/caldav/", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Имя из Basic-авторизации не подтверждено паролем и пользователя не определяет.
+	r = httptest.NewRequest(http.MethodGet, "/caldav/calendar/standup.ics", nil)
+	r.SetBasicAuth("victim", "x")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = do(h, http.MethodOptions, "/caldav/calendar/", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Header().Get("DAV"), "calendar-access")
+
+	require.Equal(t, http.StatusBadRequest, do(h, http.MethodPut, "/caldav/calendar/x.ics", "garbage").Code)
+	require.Equal(t, http.StatusForbidden, do(h, http.MethodPut, "/caldav/calendar/x.ics",
+		strings.Replace(standup, "DTEND:20210906T101500Z", "DTEND:20210906T090000Z", 1), "If-None-Match", "*").Code)
+	require.Equal(t, http.StatusBadRequest, do(h, methodPropfind, "/caldav/", "<propfind").Code)
+	require.Equal(t, http.StatusMethodNotAllowed, do(h, http.MethodPost, "/caldav/calendar/", "").Code)
+	require.Equal(t, http.StatusNotFound, do(h, methodPropfind, "/caldav/other/", "").Code)
+}
@@ -0,0 +1,201 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	// nsCS - расширения Apple Calendar Server, getctag понимают все популярные клиенты.
+	nsCS = "http://calendarserver.org/ns/"
+)
+
+var (
+	propResourceType       = xml.Name{Space: nsDAV, Local: "resourcetype"}
+	propDisplayName        = xml.Name{Space: nsDAV, Local: "displayname"}
+	propCurrentPrincipal   = xml.Name{Space: nsDAV, Local: "current-user-principal"}
+	propPrincipalURL       = xml.Name{Space: nsDAV, Local: "principal-URL"}
+	propGetETag            = xml.Name{Space: nsDAV, Local: "getetag"}
+	propGetContentType     = xml.Name{Space: nsDAV, Local: "getcontenttype"}
+	propCalendarHomeSet    = xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}
+	propSupportedComponent = xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}
+	propCalendarData       = xml.Name{Space: nsCalDAV, Local: "calendar-data"}
+	propGetCTag            = xml.Name{Space: nsCS, Local: "getctag"}
+)
+
+// resource - ресурс CalDAV и значения его свойств в виде готового XML.
+type resource struct {
+	href  string
+	props map[xml.Name]string
+}
+
+// propRequest - запрошенные свойства; all - все, кроме calendar-data (allprop).
+type propRequest struct {
+	all   bool
+	names []xml.Name
+}
+
+type multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	Responses []response `xml:"response"`
+}
+
+type response struct {
+	Href      string     `xml:"href"`
+	Propstats []propstat `xml:"propstat,omitempty"`
+	Status    string     `xml:"status,omitempty"`
+}
+
+type propstat struct {
+	Prop   props  `xml:"prop"`
+	Status string `xml:"status"`
+}
+
+type props struct {
+	Values []propValue `xml:",any"`
+}
+
+type propValue struct {
+	XMLName xml.Name
+	Inner   string `xml:",innerxml"`
+}
+
+// response отбирает у ресурса запрошенные свойства: найденные - со статусом 200,
+// неизвестные - со статусом 404, как требует RFC 4918.
+func (r resource) response(req propRequest) response {
+	var found, missing []propValue
+	if req.all {
+		for name, value := range r.props {
+			if name != propCalendarData {
+				found = append(found, propValue{XMLName: name, Inner: value})
+			}
+		}
+	}
+	for _, name := range req.names {
+		if value, ok := r.props[name]; ok {
+			found = append(found, propValue{XMLName: name, Inner: value})
+		} else {
+			missing = append(missing, propValue{XMLName: name})
+		}
+	}
+	sortProps(found)
+
+	resp := response{Href: r.href}
+	if len(found) > 0 {
+		resp.Propstats = append(resp.Propstats, propstat{Prop: props{found}, Status: statusLine(http.StatusOK)})
+	}
+	if len(missing) > 0 {
+		resp.Propstats = append(resp.Propstats, propstat{Prop: props{missing}, Status: statusLine(http.StatusNotFound)})
+	}
+	return resp
+}
+
+func sortProps(values []propValue) {
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].XMLName.Space != values[j].XMLName.Space {
+			return values[i].XMLName.Space < values[j].XMLName.Space
+		}
+		return values[i].XMLName.Local < values[j].XMLName.Local
+	})
+}
+
+func statusLine(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+func writeMultistatus(w http.ResponseWriter, responses []response) {
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(multistatus{Responses: responses})
+}
+
+// hrefXML возвращает XML элемента DAV:href.
+func hrefXML(href string) string {
+	return `<href xmlns="DAV:">` + escape(href) + `</href>`
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// Тела запросов PROPFIND и REPORT.
+
+type propfindRequest struct {
+	XMLName xml.Name   `xml:"DAV: propfind"`
+	AllProp *struct{}  `xml:"DAV: allprop"`
+	Prop    *propNames `xml:"DAV: prop"`
+}
+
+type propNames struct {
+	Names []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+func (p *propNames) request() propRequest {
+	if p == nil {
+		return propRequest{all: true}
+	}
+	req := propRequest{names: make([]xml.Name, 0, len(p.Names))}
+	for _, name := range p.Names {
+		req.names = append(req.names, name.XMLName)
+	}
+	return req
+}
+
+// reportRequest описывает calendar-query и calendar-multiget (RFC 4791, 7.8 и 7.9).
+type reportRequest struct {
+	XMLName xml.Name
+	Prop    *propNames `xml:"DAV: prop"`
+	Hrefs   []string   `xml:"DAV: href"`
+	Filter  *struct {
+		CompFilter compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+type compFilter struct {
+	Name        string       `xml:"name,attr"`
+	CompFilters []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	TimeRange   *struct {
+		Start string `xml:"start,attr"`
+		End   string `xml:"end,attr"`
+	} `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+}
+
+// period возвращает интервал фильтра VCALENDAR/VEVENT/time-range; ok - false, если
+// фильтра по времени нет. Отсутствующие границы заменяются границами всего календаря.
+func (r reportRequest) period() (from, to time.Time, ok bool, err error) {
+	from, to = allFrom, allTo
+	if r.Filter == nil {
+		return from, to, false, nil
+	}
+	for _, filter := range r.Filter.CompFilter.CompFilters {
+		if filter.Name != "VEVENT" || filter.TimeRange == nil {
+			continue
+		}
+		ok = true
+		if filter.TimeRange.Start != "" {
+			if from, err = time.Parse(timeRangeFormat, filter.TimeRange.Start); err != nil {
+				return from, to, ok, err
+			}
+		}
+		if filter.TimeRange.End != "" {
+			if to, err = time.Parse(timeRangeFormat, filter.TimeRange.End); err != nil {
+				return from, to, ok, err
+			}
+		}
+	}
+	return from, to, ok, nil
+}
+
+const timeRangeFormat = "20060102T150405Z"
@@ -0,0 +1,348 @@
+package internalgrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/ical"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) Create(ctx context.Context, req *pb.CreateRequest) (*pb.CreateResponse, error) {
+	event, err := s.app.CreateEvent(ctx, fromPB(req.GetEvent()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.CreateResponse{Event: toPB(event)}, nil
+}
+
+func (s *Server) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.UpdateResponse, error) {
+	version, err := expectedVersion(ctx, req.GetVersion())
+	if err != nil {
+		return nil, err
+	}
+	event := fromPB(req.GetEvent())
+	event.ID = req.GetId()
+	event.Version = version
+	event, err = s.app.UpdateEvent(ctx, req.GetId(), event)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.UpdateResponse{Event: toPB(event)}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	version, err := expectedVersion(ctx, req.GetVersion())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.app.DeleteEvent(ctx, req.GetId(), version); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.DeleteResponse{}, nil
+}
+
+func (s *Server) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	event, err := s.app.GetEvent(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.GetResponse{Event: toPB(event)}, nil
+}
+
+func (s *Server) Invite(ctx context.Context, req *pb.InviteRequest) (*pb.InviteResponse, error) {
+	event, err := s.app.InviteAttendees(ctx, req.GetId(), req.GetUserIds())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.InviteResponse{Event: toPB(event)}, nil
+}
+
+func (s *Server) Respond(ctx context.Context, req *pb.RespondRequest) (*pb.RespondResponse, error) {
+	event, err := s.app.RespondToInvitation(ctx, req.GetId(), storage.AttendeeStatus(req.GetStatus()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.RespondResponse{Event: toPB(event)}, nil
+}
+
+func (s *Server) ListTrash(ctx context.Context, req *pb.ListTrashRequest) (*pb.ListResponse, error) {
+	events, err := s.app.ListTrash(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toPBList(events), nil
+}
+
+func (s *Server) Restore(ctx context.Context, req *pb.RestoreRequest) (*pb.RestoreResponse, error) {
+	event, err := s.app.RestoreEvent(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.RestoreResponse{Event: toPB(event)}, nil
+}
+
+func (s *Server) ListDay(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	return list(ctx, req, s.app.ListDayEvents)
+}
+
+func (s *Server) ListWeek(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	return list(ctx, req, s.app.ListWeekEvents)
+}
+
+func (s *Server) ListMonth(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	return list(ctx, req, s.app.ListMonthEvents)
+}
+
+func (s *Server) Export(ctx context.Context, req *pb.ExportRequest) (*httpbody.HttpBody, error) {
+	if req.GetFrom() == nil || req.GetTo() == nil {
+		return nil, status.Error(codes.InvalidArgument, "from and to are required")
+	}
+	events, err := s.app.ExportEvents(ctx, req.GetFrom().AsTime(), req.GetTo().AsTime())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	var buf bytes.Buffer
+	if err := ical.Encode(&buf, events, time.Now()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &httpbody.HttpBody{ContentType: ical.ContentType, Data: buf.Bytes()}, nil
+}
+
+func (s *Server) Import(ctx context.Context, req *pb.ImportRequest) (*pb.ImportResponse, error) {
+	events, err := ical.Decode(strings.NewReader(req.GetCalendar()))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	results, err := s.app.ImportEvents(ctx, events)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &pb.ImportResponse{Results: make([]*pb.ImportResult, 0, len(results))}
+	for _, result := range results {
+		item := &pb.ImportResult{Uid: result.UID}
+		if result.Err != nil {
+			item.Error = result.Err.Error()
+		} else {
+			item.Event = toPB(result.Event)
+		}
+		resp.Results = append(resp.Results, item)
+	}
+	return resp, nil
+}
+
+func (s *Server) FreeBusy(ctx context.Context, req *pb.FreeBusyRequest) (*pb.FreeBusyResponse, error) {
+	if req.GetFrom() == nil || req.GetTo() == nil || req.GetDuration() == nil {
+		return nil, status.Error(codes.InvalidArgument, "from, to and duration are required")
+	}
+	hours, err := fromPBWorkingHours(req.GetWorkingHours())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	result, err := s.app.FindFreeBusy(ctx, app.FreeBusyQuery{
+		UserIDs:      req.GetUserIds(),
+		From:         req.GetFrom().AsTime(),
+		To:           req.GetTo().AsTime(),
+		Duration:     req.GetDuration().AsDuration(),
+		WorkingHours: hours,
+		TimeZone:     req.GetTimeZone(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.FreeBusyResponse{Busy: toPBIntervals(result.Busy), Slots: toPBIntervals(result.Slots)}, nil
+}
+
+// expectedVersion возвращает версию события, которую клиент ожидает изменить: из поля
+// запроса или, для HTTP, из заголовка If-Match. If-Match: * означает любую версию (0).
+func expectedVersion(ctx context.Context, version int64) (int64, error) {
+	if version != 0 {
+		return version, nil
+	}
+	switch tag := firstMetadata(ctx, IfMatchKey); tag {
+	case "":
+		return 0, status.Error(codes.FailedPrecondition, "event version is required: set version or If-Match")
+	case "*":
+		return 0, nil
+	default:
+		version, err := storage.ParseETag(tag)
+		if err != nil {
+			return 0, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return version, nil
+	}
+}
+
+type listFunc func(ctx context.Context, date time.Time, zone string, query app.ListQuery) (app.EventPage, error)
+
+func list(ctx context.Context, req *pb.ListRequest, fn listFunc) (*pb.ListResponse, error) {
+	if req.GetDate() == nil {
+		return nil, status.Error(codes.InvalidArgument, "date is required")
+	}
+
+	query := app.ListQuery{
+		ListFilter: storage.ListFilter{
+			Title:       req.GetTitle(),
+			HasReminder: req.HasReminder,
+			Attendee:    req.GetAttendee(),
+		},
+		PageSize: int(req.GetPageSize()),
+		Cursor:   req.GetCursor(),
+	}
+	page, err := fn(ctx, req.GetDate().AsTime(), req.GetTimeZone(), query)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := toPBList(page.Events)
+	resp.NextCursor = page.NextCursor
+	return resp, nil
+}
+
+func toPBList(events []storage.Event) *pb.ListResponse {
+	resp := &pb.ListResponse{Events: make([]*pb.Event, 0, len(events))}
+	for _, event := range events {
+		resp.Events = append(resp.Events, toPB(event))
+	}
+	return resp
+}
+
+// ReasonInvalidEvent передаётся в errdetails.ErrorInfo ошибок валидации события.
+const ReasonInvalidEvent = "INVALID_EVENT"
+
+// toStatus отображает бизнес-ошибки приложения и хранилища в коды gRPC.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, app.ErrUserRequired):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, app.ErrInvalidPeriod), errors.Is(err, app.ErrInvalidTimeZone),
+		errors.Is(err, app.ErrInvalidFreeBusy), errors.Is(err, app.ErrInvalidPage):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, storage.ErrDateBusy), errors.Is(err, storage.ErrEventExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, storage.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, app.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, storage.ErrVersionConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, storage.ErrInvalidEvent):
+		st, detailsErr := status.New(codes.InvalidArgument, err.Error()).
+			WithDetails(&errdetails.ErrorInfo{Reason: ReasonInvalidEvent, Domain: "calendar"})
+		if detailsErr != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		return st.Err()
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+func fromPB(e *pb.Event) storage.Event {
+	event := storage.Event{
+		ID:          e.GetId(),
+		Title:       e.GetTitle(),
+		Description: e.GetDescription(),
+		UserID:      e.GetUserId(),
+		RRule:       e.GetRrule(),
+		TimeZone:    e.GetTimeZone(),
+	}
+	for _, exdate := range e.GetExdates() {
+		event.ExDates = append(event.ExDates, exdate.AsTime())
+	}
+	for _, attendee := range e.GetAttendees() {
+		event.Attendees = append(event.Attendees, storage.Attendee{UserID: attendee.GetUserId()})
+	}
+	if e.GetStart() != nil {
+		event.Start = e.GetStart().AsTime()
+	}
+	if e.GetEnd() != nil {
+		event.End = e.GetEnd().AsTime()
+	}
+	if e.GetNotifyBefore() != nil {
+		event.NotifyBefore = e.GetNotifyBefore().AsDuration()
+	}
+	return event
+}
+
+func toPB(e storage.Event) *pb.Event {
+	event := &pb.Event{
+		Id:           e.ID,
+		Title:        e.Title,
+		Start:        timestamppb.New(e.Start),
+		End:          timestamppb.New(e.End),
+		Description:  e.Description,
+		UserId:       e.UserID,
+		NotifyBefore: durationpb.New(e.NotifyBefore),
+		Rrule:        e.RRule,
+		TimeZone:     e.TimeZone,
+		Version:      e.Version,
+	}
+	for _, exdate := range e.ExDates {
+		event.Exdates = append(event.Exdates, timestamppb.New(exdate))
+	}
+	for _, attendee := range e.Attendees {
+		event.Attendees = append(event.Attendees, &pb.Attendee{UserId: attendee.UserID, Status: string(attendee.Status)})
+	}
+	if !e.DeletedAt.IsZero() {
+		event.DeletedAt = timestamppb.New(e.DeletedAt)
+	}
+	return event
+}
+
+// fromPBWorkingHours разбирает рабочее время вида "09:00"-"18:00" и дни недели ISO 8601.
+func fromPBWorkingHours(h *pb.WorkingHours) (app.WorkingHours, error) {
+	var (
+		hours app.WorkingHours
+		err   error
+	)
+	if hours.Start, err = parseClock(h.GetStart()); err != nil {
+		return hours, err
+	}
+	if hours.End, err = parseClock(h.GetEnd()); err != nil {
+		return hours, err
+	}
+	for _, day := range h.GetWeekdays() {
+		if day < 1 || day > 7 {
+			return hours, fmt.Errorf("invalid weekday %d, expected 1 (Monday) to 7 (Sunday)", day)
+		}
+		hours.Weekdays = append(hours.Weekdays, time.Weekday(day%7))
+	}
+	return hours, nil
+}
+
+// parseClock разбирает время суток "15:04" в смещение от полуночи; "24:00" - конец суток.
+func parseClock(s string) (time.Duration, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "24:00":
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func toPBIntervals(intervals []app.Interval) []*pb.Interval {
+	result := make([]*pb.Interval, 0, len(intervals))
+	for _, interval := range intervals {
+		result = append(result, &pb.Interval{Start: timestamppb.New(interval.Start), End: timestamppb.New(interval.End)})
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package internalgrpc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	requestIDKey = "x-request-id"
+	userIDKey    = "user-id"
+	timeZoneKey  = "time-zone"
+	// IfMatchKey - метаданные, в которые HTTP-шлюз переносит заголовок If-Match.
+	IfMatchKey = "if-match"
+)
+
+// requestIDInterceptor берёт ID запроса из метаданных x-request-id или генерирует новый
+// и возвращает его клиенту в заголовке ответа.
+func requestIDInterceptor(
+	ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (interface{}, error) {
+	id := firstMetadata(ctx, requestIDKey)
+	if id == "" {
+		id = reqctx.NewRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+	return handler(reqctx.WithRequestID(ctx, id), req)
+}
+
+// userIDInterceptor переносит ID пользователя из метаданных user-id и его часовой пояс
+// из метаданных time-zone в контекст вызова. Если ID нет, приложение само откажет в доступе.
+func userIDInterceptor(
+	ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if id := firstMetadata(ctx, userIDKey); id != "" {
+		ctx = reqctx.WithUserID(ctx, id)
+	}
+	if zone := firstMetadata(ctx, timeZoneKey); zone != "" {
+		ctx = reqctx.WithTimeZone(ctx, zone)
+	}
+	return handler(ctx, req)
+}
+
+// loggingInterceptor логирует каждый вызов по аналогии с журналом запросов HTTP:
+// адрес клиента, метод, код ответа, время обработки и user agent.
+func (s *Server) loggingInterceptor(
+	ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	ip := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = p.Addr.String()
+		if i := strings.LastIndex(ip, ":"); i > 0 {
+			ip = ip[:i]
+		}
+	}
+
+	s.logger.InfoContext(ctx, "grpc request",
+		"ip", ip,
+		"method", info.FullMethod,
+		"code", status.Code(err).String(),
+		"latency_ms", time.Since(start).Milliseconds(),
+		"user_agent", firstMetadata(ctx, "user-agent"),
+	)
+	return resp, err
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,11 @@
+// Package pb содержит код, сгенерированный по api/EventService.proto.
+package pb
+
+import _ "embed" // OpenAPI
+
+//go:generate sh -c "cd ../../../.. && buf generate api --path api/EventService.proto"
+
+// OpenAPI - описание HTTP API в формате OpenAPI v2, построенное по тому же proto-файлу.
+//
+//go:embed EventService.swagger.json
+var OpenAPI []byte
@@ -0,0 +1,89 @@
+package internalgrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+	"google.golang.org/grpc"
+)
+
+type Server struct {
+	pb.UnimplementedEventServiceServer
+
+	logger Logger
+	app    Application
+	addr   string
+	server *grpc.Server
+}
+
+type Logger interface {
+	Info(msg string)
+	InfoContext(ctx context.Context, msg string, keyvals ...interface{})
+}
+
+type Application interface {
+	CreateEvent(ctx context.Context, event storage.Event) (storage.Event, error)
+	UpdateEvent(ctx context.Context, id string, event storage.Event) (storage.Event, error)
+	DeleteEvent(ctx context.Context, id string, version int64) error
+	GetEvent(ctx context.Context, id string) (storage.Event, error)
+	InviteAttendees(ctx context.Context, id string, userIDs []string) (storage.Event, error)
+	RespondToInvitation(ctx context.Context, id string, status storage.AttendeeStatus) (storage.Event, error)
+	ListTrash(ctx context.Context) ([]storage.Event, error)
+	RestoreEvent(ctx context.Context, id string) (storage.Event, error)
+	ListDayEvents(ctx context.Context, date time.Time, zone string, query app.ListQuery) (app.EventPage, error)
+	ListWeekEvents(ctx context.Context, start time.Time, zone string, query app.ListQuery) (app.EventPage, error)
+	ListMonthEvents(ctx context.Context, start time.Time, zone string, query app.ListQuery) (app.EventPage, error)
+	ExportEvents(ctx context.Context, from, to time.Time) ([]storage.Event, error)
+	ImportEvents(ctx context.Context, events []storage.Event) ([]app.ImportResult, error)
+	FindFreeBusy(ctx context.Context, query app.FreeBusyQuery) (app.FreeBusy, error)
+}
+
+func NewServer(logger Logger, app Application, host string, port int) *Server {
+	s := &Server{
+		logger: logger,
+		app:    app,
+		addr:   net.JoinHostPort(host, strconv.Itoa(port)),
+	}
+	s.server = grpc.NewServer(grpc.ChainUnaryInterceptor(
+		requestIDInterceptor,
+		userIDInterceptor,
+		s.loggingInterceptor,
+	))
+	pb.RegisterEventServiceServer(s.server, s)
+	return s
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("grpc server is listening on " + s.addr)
+	if err := s.server.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// Stop дожидается завершения обрабатываемых вызовов, а по истечении ctx прерывает их.
+func (s *Server) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,172 @@
+package internalgrpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	memorystorage "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newClient(t *testing.T) pb.EventServiceClient {
+	t.Helper()
+
+	logg := logger.New("error", logger.WithOutput(io.Discard))
+	s := NewServer(logg, app.New(logg, memorystorage.New()), "localhost", 0)
+
+	lis := bufconn.Listen(1024 * 1024)
+	go s.server.Serve(lis)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		s.server.Stop()
+	})
+
+	return pb.NewEventServiceClient(conn)
+}
+
+func newEvent(start time.Time) *pb.Event {
+	return &pb.Event{
+		Title:        "standup",
+		Start:        timestamppb.New(start),
+		End:          timestamppb.New(start.Add(15 * time.Minute)),
+		UserId:       "user",
+		NotifyBefore: durationpb.New(5 * time.Minute),
+	}
+}
+
+func TestServer(t *testing.T) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), userIDKey, "user")
+	client := newClient(t)
+	start := time.Date(2021, time.September, 6, 10, 0, 0, 0, time.UTC)
+
+	var header metadata.MD
+	created, err := client.Create(
+		metadata.AppendToOutgoingContext(ctx, requestIDKey, "req-1"),
+		&pb.CreateRequest{Event: newEvent(start)},
+		grpc.Header(&header),
+	)
+	require.NoError(t, err)
+	require.NotEmpty(t, created.GetEvent().GetId())
+	require.Equal(t, []string{"req-1"}, header.Get(requestIDKey))
+
+	got, err := client.Get(ctx, &pb.GetRequest{Id: created.GetEvent().GetId()})
+	require.NoError(t, err)
+	require.Equal(t, "standup", got.GetEvent().GetTitle())
+	require.Equal(t, 5*time.Minute, got.GetEvent().GetNotifyBefore().AsDuration())
+
+	_, err = client.Create(ctx, &pb.CreateRequest{Event: newEvent(start.Add(5 * time.Minute))})
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = client.Create(ctx, &pb.CreateRequest{Event: &pb.Event{Title: "empty"}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	updated := newEvent(start.AddDate(0, 0, 1))
+	updated.Title = "retro"
+	_, err = client.Update(ctx, &pb.UpdateRequest{Id: created.GetEvent().GetId(), Event: updated})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	update := &pb.UpdateRequest{Id: created.GetEvent().GetId(), Event: updated, Version: created.GetEvent().GetVersion()}
+	resp, err := client.Update(ctx, update)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), resp.GetEvent().GetVersion())
+	// Повторное изменение той же прочитанной версии - конфликт.
+	_, err = client.Update(ctx, update)
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	day, err := client.ListDay(ctx, &pb.ListRequest{Date: timestamppb.New(start)})
+	require.NoError(t, err)
+	require.Empty(t, day.GetEvents())
+
+	week, err := client.ListWeek(ctx, &pb.ListRequest{Date: timestamppb.New(start)})
+	require.NoError(t, err)
+	require.Len(t, week.GetEvents(), 1)
+	require.Equal(t, "retro", week.GetEvents()[0].GetTitle())
+
+	month, err := client.ListMonth(ctx, &pb.ListRequest{Date: timestamppb.New(start)})
+	require.NoError(t, err)
+	require.Len(t, month.GetEvents(), 1)
+
+	_, err = client.ListMonth(ctx, &pb.ListRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Get(context.Background(), &pb.GetRequest{Id: created.GetEvent().GetId()})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	other := metadata.AppendToOutgoingContext(context.Background(), userIDKey, "other")
+	_, err = client.Get(other, &pb.GetRequest{Id: created.GetEvent().GetId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.Delete(other, &pb.DeleteRequest{Id: created.GetEvent().GetId(), Version: 2})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	month, err = client.ListMonth(other, &pb.ListRequest{Date: timestamppb.New(start)})
+	require.NoError(t, err)
+	require.Empty(t, month.GetEvents())
+
+	_, err = client.Delete(ctx, &pb.DeleteRequest{Id: created.GetEvent().GetId(), Version: 2})
+	require.NoError(t, err)
+
+	_, err = client.Get(ctx, &pb.GetRequest{Id: created.GetEvent().GetId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServerListPage(t *testing.T) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), userIDKey, "user")
+	client := newClient(t)
+	start := time.Date(2021, time.September, 6, 10, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		event := newEvent(start.Add(time.Duration(i) * time.Hour))
+		if i%2 == 1 {
+			event.Title = "retro"
+			event.NotifyBefore = nil
+		}
+		_, err := client.Create(ctx, &pb.CreateRequest{Event: event})
+		require.NoError(t, err)
+	}
+
+	var titles []string
+	req := &pb.ListRequest{Date: timestamppb.New(start), PageSize: 2}
+	for pages := 1; ; pages++ {
+		resp, err := client.ListDay(ctx, req)
+		require.NoError(t, err)
+		for _, event := range resp.GetEvents() {
+			titles = append(titles, event.GetTitle())
+		}
+		if resp.GetNextCursor() == "" {
+			require.Equal(t, 3, pages)
+			break
+		}
+		req.Cursor = resp.GetNextCursor()
+	}
+	require.Equal(t, []string{"standup", "retro", "standup", "retro", "standup"}, titles)
+
+	reminds := false
+	resp, err := client.ListDay(ctx, &pb.ListRequest{Date: timestamppb.New(start), Title: "RET", HasReminder: &reminds})
+	require.NoError(t, err)
+	require.Len(t, resp.GetEvents(), 2)
+	require.Empty(t, resp.GetNextCursor())
+
+	_, err = client.ListDay(ctx, &pb.ListRequest{Date: timestamppb.New(start), Cursor: "broken"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.ListDay(ctx, &pb.ListRequest{Date: timestamppb.New(start), PageSize: -1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
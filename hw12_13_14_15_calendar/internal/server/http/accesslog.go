@@ -0,0 +1,164 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AccessLogCombined = "combined"
+	AccessLogJSON     = "json"
+
+	accessTimeFormat = "02/Jan/2006:15:04:05 -0700"
+)
+
+type accessLogger struct {
+	mu      sync.Mutex
+	w       io.Writer
+	format  string
+	trusted []*net.IPNet
+}
+
+func newAccessLogger(w io.Writer, format string, trustedProxies []string) (*accessLogger, error) {
+	switch format {
+	case "":
+		format = AccessLogCombined
+	case AccessLogCombined, AccessLogJSON:
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", format)
+	}
+
+	trusted, err := parseNetworks(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &accessLogger{w: w, format: format, trusted: trusted}, nil
+}
+
+// parseNetworks разбирает список IP-адресов и подсетей в CIDR-нотации.
+func parseNetworks(addrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(addrs))
+	for _, addr := range addrs {
+		if !strings.Contains(addr, "/") {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", addr)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", addr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func (a *accessLogger) isTrusted(ip net.IP) bool {
+	for _, network := range a.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP возвращает адрес клиента. X-Forwarded-For учитывается, только если запрос
+// пришёл от доверенного прокси: адреса из заголовка перебираются справа налево до первого
+// недоверенного.
+func (a *accessLogger) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !a.isTrusted(ip) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		fip := net.ParseIP(addr)
+		if fip == nil {
+			break
+		}
+		host = addr
+		if !a.isTrusted(fip) {
+			break
+		}
+	}
+	return host
+}
+
+type accessEntry struct {
+	IP        string    `json:"ip"`
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	URI       string    `json:"uri"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Size      int       `json:"size"`
+	LatencyMs int64     `json:"latencyMs"`
+	UserAgent string    `json:"userAgent"`
+	RequestID string    `json:"requestId,omitempty"`
+}
+
+// String форматирует запись в виде:
+// 66.249.65.3 [25/Feb/2020:19:11:24 +0600] GET /hello?q=1 HTTP/1.1 200 30 "Mozilla/5.0" 12,
+// где после кода ответа идёт размер ответа в байтах, а время обработки в миллисекундах
+// дописывается в конец, чтобы строку разбирали парсеры combined-формата.
+func (e accessEntry) String() string {
+	return fmt.Sprintf("%s [%s] %s %s %s %d %d %q %d",
+		e.IP, e.Time.Format(accessTimeFormat), e.Method, e.URI, e.Proto,
+		e.Status, e.Size, e.UserAgent, e.LatencyMs,
+	)
+}
+
+func (a *accessLogger) log(e accessEntry) {
+	var line []byte
+	if a.format == AccessLogJSON {
+		line, _ = json.Marshal(e)
+	} else {
+		line = []byte(e.String())
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.w.Write(append(line, '\n'))
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
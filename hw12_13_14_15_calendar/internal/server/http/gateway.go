@@ -0,0 +1,122 @@
+package internalhttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	internalgrpc "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/server/grpc"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// newGateway строит HTTP-обработчики по аннотациям google.api.http из EventService.proto
+// и вызывает методы сервиса напрямую, без сетевого вызова gRPC.
+func newGateway(service pb.EventServiceServer) (http.Handler, error) {
+	// HTTPBodyMarshaler отдаёт ответы google.api.HttpBody (экспорт .ics) как есть.
+	marshaler := &runtime.HTTPBodyMarshaler{Marshaler: &runtime.JSONPb{
+		MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true},
+		UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+	}}
+	gw := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, marshaler),
+		runtime.WithMarshalerOption(calendarMIME, calendarMarshaler{Marshaler: marshaler}),
+		runtime.WithErrorHandler(errorHandler),
+		runtime.WithForwardResponseOption(forwardResponse),
+		runtime.WithMetadata(ifMatchMetadata),
+	)
+	if err := pb.RegisterEventServiceHandlerServer(context.Background(), gw, service); err != nil {
+		return nil, err
+	}
+	return gw, nil
+}
+
+// errorHandler отвечает 422 на ошибки валидации события, 412 на конфликт версий
+// и 428, если версия не передана; остальные коды gRPC отображаются в HTTP стандартным
+// для grpc-gateway образом.
+func errorHandler(
+	ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler,
+	w http.ResponseWriter, r *http.Request, err error,
+) {
+	if code := httpStatus(err); code != 0 {
+		w = &statusOverrideWriter{ResponseWriter: w, status: code}
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, m, w, r, err)
+}
+
+func httpStatus(err error) int {
+	st, ok := status.FromError(err)
+	if !ok {
+		return 0
+	}
+	switch st.Code() {
+	case codes.Aborted:
+		return http.StatusPreconditionFailed
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionRequired
+	case codes.InvalidArgument:
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetReason() == internalgrpc.ReasonInvalidEvent {
+				return http.StatusUnprocessableEntity
+			}
+		}
+	}
+	return 0
+}
+
+type statusOverrideWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusOverrideWriter) WriteHeader(int) {
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// forwardResponse отдаёт версию события из ответа в заголовке ETag; её можно
+// передать в If-Match следующего изменения.
+func forwardResponse(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+	if resp, ok := resp.(interface{ GetEvent() *pb.Event }); ok && resp.GetEvent() != nil {
+		w.Header().Set("ETag", storage.ETag(resp.GetEvent().GetVersion()))
+	}
+	if _, ok := resp.(*pb.CreateResponse); ok {
+		w.WriteHeader(http.StatusCreated)
+	}
+	return nil
+}
+
+// ifMatchMetadata передаёт заголовок If-Match методам Update и Delete.
+func ifMatchMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	if tag := r.Header.Get("If-Match"); tag != "" {
+		return metadata.Pairs(internalgrpc.IfMatchKey, tag)
+	}
+	return nil
+}
+
+const calendarMIME = "text/calendar"
+
+// calendarMarshaler принимает тело запроса text/calendar целиком как строковое поле
+// сообщения (ImportRequest.calendar); ответы кодируются как обычно.
+type calendarMarshaler struct {
+	runtime.Marshaler
+}
+
+func (m calendarMarshaler) NewDecoder(r io.Reader) runtime.Decoder {
+	return runtime.DecoderFunc(func(v interface{}) error {
+		s, ok := v.(*string)
+		if !ok {
+			return fmt.Errorf("%s body cannot be decoded into %T", calendarMIME, v)
+		}
+		data, err := io.ReadAll(r)
+		*s = string(data)
+		return err
+	})
+}
@@ -0,0 +1,597 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type eventResponse struct {
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	Start        string `json:"start"`
+	End          string `json:"end"`
+	UserID       string `json:"userId"`
+	NotifyBefore string `json:"notifyBefore"`
+	TimeZone     string `json:"timeZone"`
+	DeletedAt    string `json:"deletedAt"`
+	Attendees    []struct {
+		UserID string `json:"userId"`
+		Status string `json:"status"`
+	} `json:"attendees"`
+}
+
+type errorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func doRequest(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	return doRequestAs(s, "user", method, target, body)
+}
+
+// doRequestAs выполняет запрос от имени пользователя userID, пустой userID - анонимно.
+func doRequestAs(s *Server, userID, method, target, body string) *httptest.ResponseRecorder {
+	var r *http.Request
+	if body == "" {
+		r = httptest.NewRequest(method, target, nil)
+	} else {
+		r = httptest.NewRequest(method, target, strings.NewReader(body))
+	}
+	if userID != "" {
+		r.Header.Set(userIDHeader, userID)
+	}
+	return serve(s, r)
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+}
+
+func TestEventsAPI(t *testing.T) {
+	s := newTestServer(t, Config{})
+
+	w := doRequest(s, http.MethodPost, "/events", `{
+		"title": "standup",
+		"start": "2021-09-06T10:00:00Z",
+		"end": "2021-09-06T10:15:00Z",
+		"userId": "user",
+		"notifyBefore": "300s"
+	}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Event eventResponse `json:"event"`
+	}
+	decode(t, w, &created)
+	require.NotEmpty(t, created.Event.ID)
+	require.Equal(t, "standup", created.Event.Title)
+	require.Equal(t, "300s", created.Event.NotifyBefore)
+	require.Equal(t, "user", created.Event.UserID)
+
+	w = doRequest(s, http.MethodGet, "/events/"+created.Event.ID, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var got struct {
+		Event eventResponse `json:"event"`
+	}
+	decode(t, w, &got)
+	require.Equal(t, created, got)
+	require.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+	w = doRequest(s, http.MethodPut, "/events/"+created.Event.ID+"?version=1", `{
+		"title": "retro",
+		"start": "2021-09-07T10:00:00Z",
+		"end": "2021-09-07T11:00:00Z",
+		"userId": "user"
+	}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	for _, tc := range []struct {
+		target string
+		count  int
+	}{
+		{target: "/events/day?date=2021-09-06T00:00:00Z", count: 0},
+		{target: "/events/day?date=2021-09-07T00:00:00Z", count: 1},
+		{target: "/events/week?date=2021-09-06T00:00:00Z", count: 1},
+		{target: "/events/month?date=2021-08-15T00:00:00Z", count: 1},
+	} {
+		w = doRequest(s, http.MethodGet, tc.target, "")
+		require.Equal(t, http.StatusOK, w.Code, tc.target)
+		var list struct {
+			Events []eventResponse `json:"events"`
+		}
+		decode(t, w, &list)
+		require.Len(t, list.Events, tc.count, tc.target)
+	}
+
+	w = doRequest(s, http.MethodDelete, "/events/"+created.Event.ID+"?version=2", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(s, http.MethodGet, "/events/"+created.Event.ID, "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestEventsAPIErrors(t *testing.T) {
+	s := newTestServer(t, Config{})
+	event := `{"id": "1", "title": "t", "start": "2021-09-06T10:00:00Z", "end": "2021-09-06T11:00:00Z", "userId": "u"}`
+	require.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/events", event).Code)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{name: "duplicate", method: http.MethodPost, target: "/events", body: event, status: http.StatusConflict},
+		{
+			name: "date busy", method: http.MethodPost, target: "/events", status: http.StatusConflict,
+			body: `{"title": "t", "start": "2021-09-06T10:30:00Z", "end": "2021-09-06T11:30:00Z", "userId": "u"}`,
+		},
+		{
+			name: "invalid", method: http.MethodPost, target: "/events", status: http.StatusUnprocessableEntity,
+			body: `{"title": "", "start": "2021-09-07T10:00:00Z", "end": "2021-09-07T11:00:00Z", "userId": "u"}`,
+		},
+		{name: "bad json", method: http.MethodPost, target: "/events", body: `{`, status: http.StatusBadRequest},
+		{
+			name: "bad duration", method: http.MethodPost, target: "/events", status: http.StatusBadRequest,
+			body: `{"title": "t", "notifyBefore": "soon"}`,
+		},
+		{
+			name: "update missing", method: http.MethodPut, target: "/events/2?version=1", status: http.StatusNotFound,
+			body: `{"title": "t", "start": "2021-09-07T10:00:00Z", "end": "2021-09-07T11:00:00Z", "userId": "u"}`,
+		},
+		{name: "delete missing", method: http.MethodDelete, target: "/events/2?version=1", status: http.StatusNotFound},
+		{
+			name: "update without version", method: http.MethodPut, target: "/events/1",
+			status: http.StatusPreconditionRequired,
+			body:   `{"title": "t", "start": "2021-09-07T10:00:00Z", "end": "2021-09-07T11:00:00Z", "userId": "u"}`,
+		},
+		{
+			name: "stale version", method: http.MethodDelete, target: "/events/1?version=7",
+			status: http.StatusPreconditionFailed,
+		},
+		{
+			name: "invalid rrule", method: http.MethodPost, target: "/events", status: http.StatusUnprocessableEntity,
+			body: `{"title": "t", "start": "2021-09-08T10:00:00Z", "end": "2021-09-08T11:00:00Z", "rrule": "FREQ=HOURLY"}`,
+		},
+		{name: "bad date", method: http.MethodGet, target: "/events/day?date=06.09.2021", status: http.StatusBadRequest},
+		{name: "missing date", method: http.MethodGet, target: "/events/day", status: http.StatusBadRequest},
+		{
+			name: "unknown list time zone", method: http.MethodGet, status: http.StatusBadRequest,
+			target: "/events/day?date=2021-09-06T00:00:00Z&time_zone=Mars/Olympus",
+		},
+		{
+			name: "unknown event time zone", method: http.MethodPost, target: "/events", status: http.StatusUnprocessableEntity,
+			body: `{"title": "t", "start": "2021-09-08T10:00:00Z", "end": "2021-09-08T11:00:00Z", "timeZone": "Local"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			w := doRequest(s, tc.method, tc.target, tc.body)
+			require.Equal(t, tc.status, w.Code)
+			var resp errorResponse
+			decode(t, w, &resp)
+			require.NotEmpty(t, resp.Message)
+		})
+	}
+}
+
+func TestEventsAPIRecurring(t *testing.T) {
+	s := newTestServer(t, Config{})
+
+	w := doRequest(s, http.MethodPost, "/events", `{
+		"title": "standup",
+		"start": "2021-09-06T10:00:00Z",
+		"end": "2021-09-06T10:15:00Z",
+		"rrule": "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+		"exdates": ["2021-09-08T10:00:00Z"]
+	}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = doRequest(s, http.MethodGet, "/events/week?date=2021-09-13T00:00:00Z", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Events []eventResponse `json:"events"`
+	}
+	decode(t, w, &list)
+	starts := make([]string, 0, len(list.Events))
+	for _, event := range list.Events {
+		starts = append(starts, event.Start)
+	}
+	require.Equal(t, []string{"2021-09-13T10:00:00Z", "2021-09-15T10:00:00Z", "2021-09-17T10:00:00Z"}, starts)
+
+	w = doRequest(s, http.MethodGet, "/events/week?date=2021-09-06T00:00:00Z", "")
+	decode(t, w, &list)
+	require.Len(t, list.Events, 2)
+
+	// Событие в исключённое время не конфликтует с серией, в любое другое - конфликтует.
+	w = doRequest(s, http.MethodPost, "/events",
+		`{"title": "t", "start": "2021-09-08T10:00:00Z", "end": "2021-09-08T11:00:00Z"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = doRequest(s, http.MethodPost, "/events",
+		`{"title": "t", "start": "2022-03-04T10:00:00Z", "end": "2022-03-04T11:00:00Z"}`)
+	require.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestEventsAPITimeZone(t *testing.T) {
+	s := newTestServer(t, Config{})
+
+	// Ежедневно в 9:00 по Берлину; 31 октября 2021 Германия перешла на зимнее время.
+	w := doRequest(s, http.MethodPost, "/events", `{
+		"id": "standup",
+		"title": "standup",
+		"start": "2021-10-29T07:00:00Z",
+		"end": "2021-10-29T07:15:00Z",
+		"rrule": "FREQ=DAILY;COUNT=5",
+		"timeZone": "Europe/Berlin"
+	}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Event eventResponse `json:"event"`
+	}
+	decode(t, w, &created)
+	require.Equal(t, "Europe/Berlin", created.Event.TimeZone)
+
+	// 23:30 31 октября по Берлину, 8:30 1 ноября во Владивостоке.
+	w = doRequest(s, http.MethodPost, "/events",
+		`{"id": "late", "title": "late", "start": "2021-10-31T22:30:00Z", "end": "2021-10-31T22:45:00Z"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	list := func(target, zone string) []string {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set(userIDHeader, "user")
+		if zone != "" {
+			r.Header.Set(timeZoneHeader, zone)
+		}
+		w := serve(s, r)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp struct {
+			Events []eventResponse `json:"events"`
+		}
+		decode(t, w, &resp)
+		result := make([]string, 0, len(resp.Events))
+		for _, event := range resp.Events {
+			result = append(result, event.ID+" "+event.Start)
+		}
+		return result
+	}
+
+	// Сутки перехода длятся 25 часов, и серия остаётся в 9:00 по местному времени.
+	require.Equal(t, []string{"standup 2021-10-31T08:00:00Z", "late 2021-10-31T22:30:00Z"},
+		list("/events/day?date=2021-10-31T12:00:00Z&time_zone=Europe/Berlin", ""))
+	require.Equal(t, []string{"standup 2021-11-01T08:00:00Z"},
+		list("/events/day?date=2021-11-01T12:00:00Z&time_zone=Europe/Berlin", ""))
+	require.Equal(t, []string{"late 2021-10-31T22:30:00Z", "standup 2021-11-01T08:00:00Z"},
+		list("/events/day?date=2021-11-01T00:00:00Z", "Asia/Vladivostok"))
+	// Параметр запроса важнее пояса пользователя.
+	require.Equal(t, []string{"standup 2021-11-01T08:00:00Z"},
+		list("/events/day?date=2021-11-01T12:00:00Z&time_zone=UTC", "Asia/Vladivostok"))
+}
+
+func TestEventsAPIListPage(t *testing.T) {
+	s := newTestServer(t, Config{})
+	for _, body := range []string{
+		`{"id": "a", "title": "a", "start": "2021-09-06T09:00:00Z", "end": "2021-09-06T10:00:00Z"}`,
+		`{"id": "b", "title": "b", "start": "2021-09-06T10:00:00Z", "end": "2021-09-06T11:00:00Z"}`,
+		`{"id": "c", "title": "c", "start": "2021-09-06T11:00:00Z", "end": "2021-09-06T12:00:00Z", "notifyBefore": "60s"}`,
+	} {
+		require.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/events", body).Code)
+	}
+
+	type page struct {
+		Events     []eventResponse `json:"events"`
+		NextCursor string          `json:"nextCursor"`
+	}
+	w := doRequest(s, http.MethodGet, "/events/day?date=2021-09-06T00:00:00Z&page_size=1&has_reminder=false", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var first page
+	decode(t, w, &first)
+	require.Len(t, first.Events, 1)
+	require.Equal(t, "a", first.Events[0].ID)
+	require.NotEmpty(t, first.NextCursor)
+
+	w = doRequest(s, http.MethodGet,
+		"/events/day?date=2021-09-06T00:00:00Z&page_size=1&has_reminder=false&cursor="+first.NextCursor, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var second page
+	decode(t, w, &second)
+	require.Len(t, second.Events, 1)
+	require.Equal(t, "b", second.Events[0].ID)
+	require.Empty(t, second.NextCursor)
+
+	w = doRequest(s, http.MethodGet, "/events/day?date=2021-09-06T00:00:00Z&page_size=-1", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestFreeBusyAPI(t *testing.T) {
+	s := newTestServer(t, Config{})
+	require.Equal(t, http.StatusCreated, doRequestAs(s, "alice", http.MethodPost, "/events",
+		`{"title": "t", "start": "2021-09-06T10:00:00Z", "end": "2021-09-06T11:00:00Z"}`).Code)
+	require.Equal(t, http.StatusCreated, doRequestAs(s, "bob", http.MethodPost, "/events",
+		`{"title": "t", "start": "2021-09-06T12:00:00Z", "end": "2021-09-06T16:30:00Z"}`).Code)
+
+	w := doRequestAs(s, "alice", http.MethodPost, "/freebusy", `{
+		"userIds": ["alice", "bob"],
+		"from": "2021-09-06T00:00:00Z",
+		"to": "2021-09-08T00:00:00Z",
+		"duration": "5400s",
+		"workingHours": {"start": "09:00", "end": "18:00", "weekdays": [1, 2, 3, 4, 5]},
+		"timeZone": "UTC"
+	}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	type interval struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+	}
+	var resp struct {
+		Busy  []interval `json:"busy"`
+		Slots []interval `json:"slots"`
+	}
+	decode(t, w, &resp)
+	require.Equal(t, []interval{
+		{Start: "2021-09-06T10:00:00Z", End: "2021-09-06T11:00:00Z"},
+		{Start: "2021-09-06T12:00:00Z", End: "2021-09-06T16:30:00Z"},
+	}, resp.Busy)
+	require.Equal(t, []interval{
+		{Start: "2021-09-06T16:30:00Z", End: "2021-09-06T18:00:00Z"},
+		{Start: "2021-09-07T09:00:00Z", End: "2021-09-07T18:00:00Z"},
+	}, resp.Slots)
+
+	for body, status := range map[string]int{
+		`{"userIds": ["alice"], "from": "2021-09-06T00:00:00Z", "to": "2021-09-07T00:00:00Z"}`: http.StatusBadRequest,
+		`{"userIds": ["alice"], "from": "2021-09-06T00:00:00Z", "to": "2021-09-07T00:00:00Z", "duration": "60s",
+			"workingHours": {"start": "9am"}}`: http.StatusBadRequest,
+		`{"from": "2021-09-06T00:00:00Z", "to": "2021-09-07T00:00:00Z", "duration": "60s"}`: http.StatusBadRequest,
+	} {
+		require.Equal(t, status, doRequestAs(s, "alice", http.MethodPost, "/freebusy", body).Code, body)
+	}
+	require.Equal(t, http.StatusUnauthorized, doRequestAs(s, "", http.MethodPost, "/freebusy",
+		`{"userIds": ["alice"], "from": "2021-09-06T00:00:00Z", "to": "2021-09-07T00:00:00Z", "duration": "60s"}`).Code)
+}
+
+func TestEventsAPICalendarFile(t *testing.T) {
+	s := newTestServer(t, Config{})
+	require.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/events",
+		`{"id": "busy", "title": "t", "start": "2021-09-07T10:00:00Z", "end": "2021-09-07T11:00:00Z"}`).Code)
+
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:standup",
+		"DTSTART:20210906T090000Z",
+		"DTEND:20210906T091500Z",
+		"RRULE:FREQ=DAILY;COUNT=3",
+		"SUMMARY:Standup",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:conflict",
+		"DTSTART:20210907T103000Z",
+		"DTEND:20210907T113000Z",
+		"SUMMARY:Conflict",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+	r := httptest.NewRequest(http.MethodPost, "/events/import", strings.NewReader(ics))
+	r.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	r.Header.Set(userIDHeader, "user")
+	w := serve(s, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	var imported struct {
+		Results []struct {
+			UID   string         `json:"uid"`
+			Event *eventResponse `json:"event"`
+			Error string         `json:"error"`
+		} `json:"results"`
+	}
+	decode(t, w, &imported)
+	require.Len(t, imported.Results, 2)
+	require.Equal(t, "standup", imported.Results[0].UID)
+	require.Empty(t, imported.Results[0].Error)
+	// Внутренний идентификатор назначается сервером, UID хранится отдельно.
+	require.NotEmpty(t, imported.Results[0].Event.ID)
+	require.NotEqual(t, "standup", imported.Results[0].Event.ID)
+	require.Equal(t, "conflict", imported.Results[1].UID)
+	require.Nil(t, imported.Results[1].Event)
+	require.Contains(t, imported.Results[1].Error, "busy")
+
+	w = doRequest(s, http.MethodGet, "/events/export?from=2021-09-07T00:00:00Z&to=2021-09-08T00:00:00Z", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	require.Equal(t, 2, strings.Count(body, "BEGIN:VEVENT"))
+	// Серия экспортируется целиком, а не повторением за выбранный день.
+	require.Contains(t, body, "DTSTART:20210906T090000Z\r\n")
+	require.Contains(t, body, "RRULE:FREQ=DAILY;COUNT=3\r\n")
+	require.Contains(t, body, "UID:busy\r\n")
+	require.Contains(t, body, "UID:standup\r\n")
+
+	// Повторный импорт того же UID не создаёт дубликат.
+	r = httptest.NewRequest(http.MethodPost, "/events/import", strings.NewReader(ics))
+	r.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	r.Header.Set(userIDHeader, "user")
+	w = serve(s, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	decode(t, w, &imported)
+	require.Len(t, imported.Results, 2)
+	require.Nil(t, imported.Results[0].Event)
+	require.Contains(t, imported.Results[0].Error, "exists")
+
+	w = doRequest(s, http.MethodPost, "/events/import", `{"calendar": "garbage"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = doRequest(s, http.MethodGet, "/events/export?from=2021-09-08T00:00:00Z&to=2021-09-07T00:00:00Z", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestEventsAPIUsers(t *testing.T) {
+	s := newTestServer(t, Config{})
+	event := `{"id": "1", "title": "t", "start": "2021-09-06T10:00:00Z", "end": "2021-09-06T11:00:00Z"}`
+
+	require.Equal(t, http.StatusUnauthorized, doRequestAs(s, "", http.MethodPost, "/events", event).Code)
+	require.Equal(t, http.StatusCreated, doRequestAs(s, "alice", http.MethodPost, "/events", event).Code)
+
+	// Владелец события определяется заголовком, а не телом запроса.
+	w := doRequestAs(s, "bob", http.MethodPost, "/events",
+		`{"title": "t", "start": "2021-09-06T10:00:00Z", "end": "2021-09-06T11:00:00Z", "userId": "alice"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Event eventResponse `json:"event"`
+	}
+	decode(t, w, &created)
+	require.Equal(t, "bob", created.Event.UserID)
+
+	require.Equal(t, http.StatusOK, doRequestAs(s, "alice", http.MethodGet, "/events/1", "").Code)
+	require.Equal(t, http.StatusNotFound, doRequestAs(s, "bob", http.MethodGet, "/events/1", "").Code)
+	require.Equal(t, http.StatusNotFound, doRequestAs(s, "bob", http.MethodPut, "/events/1?version=1", event).Code)
+	require.Equal(t, http.StatusNotFound, doRequestAs(s, "bob", http.MethodDelete, "/events/1?version=1", "").Code)
+	require.Equal(t, http.StatusUnauthorized, doRequestAs(s, "", http.MethodGet, "/events/1", "").Code)
+
+	w = doRequestAs(s, "bob", http.MethodGet, "/events/day?date=2021-09-06T00:00:00Z", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Events []eventResponse `json:"events"`
+	}
+	decode(t, w, &list)
+	require.Len(t, list.Events, 1)
+	require.Equal(t, created.Event.ID, list.Events[0].ID)
+}
+
+func TestEventsAPIAttendees(t *testing.T) {
+	s := newTestServer(t, Config{})
+	require.Equal(t, http.StatusCreated, doRequestAs(s, "alice", http.MethodPost, "/events", `{
+		"id": "1", "title": "t", "start": "2021-09-06T10:00:00Z", "end": "2021-09-06T11:00:00Z",
+		"attendees": [{"userId": "bob", "status": "accepted"}]
+	}`).Code)
+
+	w := doRequestAs(s, "alice", http.MethodPost, "/events/1/attendees", `{"userIds": ["bob", "carol"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Event eventResponse `json:"event"`
+	}
+	decode(t, w, &resp)
+	require.Len(t, resp.Event.Attendees, 2)
+	// Статус из тела запроса игнорируется: ответить может только сам участник.
+	require.Equal(t, "pending", resp.Event.Attendees[0].Status)
+	require.Equal(t, "carol", resp.Event.Attendees[1].UserID)
+
+	w = doRequestAs(s, "bob", http.MethodPost, "/events/1/response", `{"status": "accepted"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decode(t, w, &resp)
+	require.Equal(t, "accepted", resp.Event.Attendees[0].Status)
+
+	// Участник видит событие в своих списках, но изменить его не может.
+	w = doRequestAs(s, "bob", http.MethodGet, "/events/day?date=2021-09-06T00:00:00Z", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Events []eventResponse `json:"events"`
+	}
+	decode(t, w, &list)
+	require.Len(t, list.Events, 1)
+	require.Equal(t, "alice", list.Events[0].UserID)
+	require.Equal(t, http.StatusOK, doRequestAs(s, "carol", http.MethodGet, "/events/1", "").Code)
+	require.Equal(t, http.StatusForbidden, doRequestAs(s, "bob", http.MethodDelete, "/events/1?version=1", "").Code)
+	require.Equal(t, http.StatusForbidden,
+		doRequestAs(s, "bob", http.MethodPost, "/events/1/attendees", `{"userIds": ["dave"]}`).Code)
+
+	for user, status := range map[string]int{"dave": http.StatusNotFound, "alice": http.StatusNotFound} {
+		require.Equal(t, status,
+			doRequestAs(s, user, http.MethodPost, "/events/1/response", `{"status": "declined"}`).Code, user)
+	}
+	require.Equal(t, http.StatusUnprocessableEntity,
+		doRequestAs(s, "carol", http.MethodPost, "/events/1/response", `{"status": "maybe"}`).Code)
+	require.Equal(t, http.StatusUnprocessableEntity,
+		doRequestAs(s, "alice", http.MethodPost, "/events/1/attendees", `{"userIds": ["alice"]}`).Code)
+}
+
+func TestEventsAPIIfMatch(t *testing.T) {
+	s := newTestServer(t, Config{})
+	w := doRequest(s, http.MethodPost, "/events",
+		`{"id": "1", "title": "t", "start": "2021-09-06T10:00:00Z", "end": "2021-09-06T11:00:00Z"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	tag := w.Header().Get("ETag")
+	require.Equal(t, `"1"`, tag)
+
+	update := func(ifMatch, title string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, "/events/1", strings.NewReader(
+			`{"title": "`+title+`", "start": "2021-09-06T10:00:00Z", "end": "2021-09-06T11:00:00Z"}`))
+		r.Header.Set(userIDHeader, "user")
+		r.Header.Set("If-Match", ifMatch)
+		return serve(s, r)
+	}
+
+	// Два клиента прочитали версию 1: второй получает 412 и не затирает изменения первого.
+	w = update(tag, "first")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, `"2"`, w.Header().Get("ETag"))
+	require.Equal(t, http.StatusPreconditionFailed, update(tag, "second").Code)
+	require.Equal(t, http.StatusBadRequest, update("W/1", "second").Code)
+	require.Equal(t, http.StatusOK, update("*", "third").Code)
+
+	var got struct {
+		Event eventResponse `json:"event"`
+	}
+	w = doRequest(s, http.MethodGet, "/events/1", "")
+	decode(t, w, &got)
+	require.Equal(t, "third", got.Event.Title)
+	require.Equal(t, `"3"`, w.Header().Get("ETag"))
+}
+
+func TestEventsAPITrash(t *testing.T) {
+	s := newTestServer(t, Config{})
+	event := `{"id": "1", "title": "t", "start": "2021-09-06T10:00:00Z", "end": "2021-09-06T11:00:00Z"}`
+	require.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/events", event).Code)
+	require.Equal(t, http.StatusOK, doRequest(s, http.MethodDelete, "/events/1?version=1", "").Code)
+	require.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/events/1", "").Code)
+
+	trash := func(userID string) []eventResponse {
+		w := doRequestAs(s, userID, http.MethodGet, "/events/trash", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var list struct {
+			Events []eventResponse `json:"events"`
+		}
+		decode(t, w, &list)
+		return list.Events
+	}
+	deleted := trash("user")
+	require.Len(t, deleted, 1)
+	require.Equal(t, "1", deleted[0].ID)
+	require.NotEmpty(t, deleted[0].DeletedAt)
+	require.Empty(t, trash("other"))
+
+	// Пока событие в корзине, его время можно занять - тогда восстановить его нельзя.
+	require.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/events",
+		`{"id": "2", "title": "t", "start": "2021-09-06T10:30:00Z", "end": "2021-09-06T11:30:00Z"}`).Code)
+	require.Equal(t, http.StatusConflict, doRequest(s, http.MethodPost, "/events/1/restore", "").Code)
+	require.Equal(t, http.StatusOK, doRequest(s, http.MethodDelete, "/events/2?version=1", "").Code)
+
+	require.Equal(t, http.StatusNotFound, doRequestAs(s, "other", http.MethodPost, "/events/1/restore", "").Code)
+	w := doRequest(s, http.MethodPost, "/events/1/restore", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, `"3"`, w.Header().Get("ETag"))
+	require.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/events/1", "").Code)
+	require.Len(t, trash("user"), 1)
+}
+
+func TestOpenAPI(t *testing.T) {
+	s := newTestServer(t, Config{})
+
+	w := doRequest(s, http.MethodGet, "/openapi.json", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var doc struct {
+		Swagger string                 `json:"swagger"`
+		Paths   map[string]interface{} `json:"paths"`
+	}
+	decode(t, w, &doc)
+	require.Equal(t, "2.0", doc.Swagger)
+	require.Contains(t, doc.Paths, "/events/{id}")
+	require.Contains(t, doc.Paths, "/events/week")
+}
@@ -2,10 +2,106 @@ package internalhttp
 
 import (
 	"net/http"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	userIDHeader    = "X-User-ID"
+	timeZoneHeader  = "X-Time-Zone"
 )
 
-func loggingMiddleware(next http.Handler) http.Handler {
+func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+
+		next.ServeHTTP(rw, r)
+
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		s.accessLog.log(accessEntry{
+			IP:        s.accessLog.clientIP(r),
+			Time:      start,
+			Method:    r.Method,
+			URI:       r.URL.RequestURI(),
+			Proto:     r.Proto,
+			Status:    rw.status,
+			Size:      rw.size,
+			LatencyMs: time.Since(start).Milliseconds(),
+			UserAgent: r.UserAgent(),
+			RequestID: reqctx.RequestID(r.Context()),
+		})
+	})
+}
+
+// requestIDMiddleware берёт ID запроса из заголовка X-Request-ID или генерирует новый,
+// кладёт его в контекст запроса и возвращает клиенту в том же заголовке.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" {
+			id = reqctx.NewRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(reqctx.WithRequestID(r.Context(), id)))
+	})
+}
+
+// userIDMiddleware переносит ID пользователя из заголовка X-User-ID и его часовой пояс
+// из заголовка X-Time-Zone в контекст запроса. Если ID нет, приложение само откажет в доступе.
+func userIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := r.Header.Get(userIDHeader); id != "" {
+			r = r.WithContext(reqctx.WithUserID(r.Context(), id))
+		}
+		if zone := r.Header.Get(timeZoneHeader); zone != "" {
+			r = r.WithContext(reqctx.WithTimeZone(r.Context(), zone))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !originAllowed(s.getState().settings.AllowedOrigins, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Expose-Headers", requestIDHeader+", ETag")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers",
+				"Content-Type, If-Match, "+requestIDHeader+", "+userIDHeader+", "+timeZoneHeader)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// TODO
+		if !s.getState().limiter.Allow() {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
 	})
 }
+
+func originAllowed(allowed []string, origin string) bool {
+	for _, o := range allowed {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package internalhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggingMiddleware(t *testing.T) {
+	t.Run("combined", func(t *testing.T) {
+		var buf bytes.Buffer
+		s := newTestServer(t, Config{AccessLog: &buf})
+
+		r := httptest.NewRequest(http.MethodGet, "/hello?q=1", nil)
+		r.RemoteAddr = "66.249.65.3:41234"
+		r.Header.Set("User-Agent", "Mozilla/5.0")
+		serve(s, r)
+
+		require.Regexp(t,
+			regexp.MustCompile(`^66\.249\.65\.3 \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] `+
+				`GET /hello\?q=1 HTTP/1\.1 200 5 "Mozilla/5\.0" \d+\n$`),
+			buf.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		s := newTestServer(t, Config{AccessLog: &buf, AccessLogFormat: AccessLogJSON})
+
+		r := httptest.NewRequest(http.MethodGet, "/unknown", nil)
+		r.Header.Set(requestIDHeader, "req-1")
+		serve(s, r)
+
+		var entry accessEntry
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		require.Equal(t, http.MethodGet, entry.Method)
+		require.Equal(t, "/unknown", entry.URI)
+		require.Equal(t, http.StatusNotFound, entry.Status)
+		require.Equal(t, "req-1", entry.RequestID)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := NewServer(nopLogger{}, nil, Config{AccessLogFormat: "xml"})
+		require.Error(t, err)
+
+		_, err = NewServer(nopLogger{}, nil, Config{TrustedProxies: []string{"proxy"}})
+		require.Error(t, err)
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	a, err := newAccessLogger(nil, "", []string{"10.0.0.0/8", "127.0.0.1"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		expected  string
+	}{
+		{name: "direct", remote: "66.249.65.3:1234", expected: "66.249.65.3"},
+		{
+			name: "untrusted proxy", remote: "66.249.65.3:1234",
+			forwarded: []string{"1.1.1.1"}, expected: "66.249.65.3",
+		},
+		{
+			name: "trusted proxy", remote: "127.0.0.1:1234",
+			forwarded: []string{"1.1.1.1"}, expected: "1.1.1.1",
+		},
+		{
+			name: "proxy chain", remote: "10.0.0.2:1234",
+			forwarded: []string{"6.6.6.6, 2.2.2.2", "10.0.0.1"}, expected: "2.2.2.2",
+		},
+		{
+			name: "all trusted", remote: "10.0.0.2:1234",
+			forwarded: []string{"10.0.0.1"}, expected: "10.0.0.1",
+		},
+		{
+			name: "garbage", remote: "10.0.0.2:1234",
+			forwarded: []string{"unknown"}, expected: "10.0.0.2",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.remote
+			for _, v := range tc.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			require.Equal(t, tc.expected, a.clientIP(r))
+		})
+	}
+}
@@ -2,30 +2,133 @@ package internalhttp
 
 import (
 	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/server/caldav"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"golang.org/x/time/rate"
 )
 
-type Server struct { // TODO
+const readHeaderTimeout = 10 * time.Second
+
+type Server struct {
+	logger    Logger
+	server    *http.Server
+	accessLog *accessLogger
+	// state хранит *serverState и атомарно подменяется при изменении настроек.
+	state atomic.Value
+}
+
+type serverState struct {
+	settings Settings
+	limiter  *rate.Limiter
+}
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+}
+
+// Settings - параметры сервера, которые можно менять без перезапуска.
+type Settings struct {
+	// AllowedOrigins - список источников для CORS, "*" разрешает любой.
+	AllowedOrigins []string
+	// RateLimit - допустимое число запросов в секунду, 0 - без ограничений.
+	RateLimit float64
+	RateBurst int
+}
+
+type Config struct {
+	Host string
+	Port int
+	// TrustedProxies - IP-адреса и подсети прокси, которым доверяется X-Forwarded-For.
+	TrustedProxies []string
+	// AccessLog - назначение журнала запросов, AccessLogFormat - combined или json.
+	AccessLog       io.Writer
+	AccessLogFormat string
+	Settings        Settings
+	// CalDAV - обработчик CalDAV, монтируется в caldav.Prefix; nil - CalDAV отключён.
+	CalDAV http.Handler
 }
 
-type Logger interface { // TODO
+// NewServer создаёт HTTP-сервер, маршруты API которого построены по контракту gRPC-сервиса.
+func NewServer(logger Logger, service pb.EventServiceServer, conf Config) (*Server, error) {
+	accessLog, err := newAccessLogger(conf.AccessLog, conf.AccessLogFormat, conf.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	gateway, err := newGateway(service)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		logger:    logger,
+		accessLog: accessLog,
+	}
+	s.SetSettings(conf.Settings)
+
+	router := http.NewServeMux()
+	router.HandleFunc("/hello", s.hello)
+	router.HandleFunc("/openapi.json", s.openAPI)
+	router.Handle("/", gateway)
+	if conf.CalDAV != nil {
+		router.Handle(caldav.Prefix, conf.CalDAV)
+		// RFC 6764: клиенты ищут сервер CalDAV по well-known адресу.
+		router.Handle("/.well-known/caldav", http.RedirectHandler(caldav.Prefix, http.StatusMovedPermanently))
+	}
+
+	handler := s.loggingMiddleware(s.rateLimitMiddleware(s.corsMiddleware(router)))
+	s.server = &http.Server{
+		Addr:              net.JoinHostPort(conf.Host, strconv.Itoa(conf.Port)),
+		Handler:           requestIDMiddleware(userIDMiddleware(handler)),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	return s, nil
 }
 
-type Application interface { // TODO
+// SetSettings применяет новые настройки к уже работающему серверу.
+func (s *Server) SetSettings(settings Settings) {
+	limit := rate.Inf
+	if settings.RateLimit > 0 {
+		limit = rate.Limit(settings.RateLimit)
+	}
+	s.state.Store(&serverState{
+		settings: settings,
+		limiter:  rate.NewLimiter(limit, settings.RateBurst),
+	})
 }
 
-func NewServer(logger Logger, app Application) *Server {
-	return &Server{}
+func (s *Server) getState() *serverState {
+	return s.state.Load().(*serverState)
 }
 
 func (s *Server) Start(ctx context.Context) error {
-	// TODO
-	<-ctx.Done()
+	s.logger.Info("http server is listening on " + s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
 	return nil
 }
 
+// Stop прекращает приём новых соединений и ждёт завершения обрабатываемых запросов,
+// но не дольше, чем позволяет ctx.
 func (s *Server) Stop(ctx context.Context) error {
-	// TODO
-	return nil
+	return s.server.Shutdown(ctx)
+}
+
+func (s *Server) hello(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("hello"))
 }
 
-// TODO
+func (s *Server) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(pb.OpenAPI)
+}
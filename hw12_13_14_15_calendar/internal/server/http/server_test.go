@@ -0,0 +1,94 @@
+package internalhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/reqctx"
+	internalgrpc "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/server/grpc"
+	memorystorage "github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string)  {}
+func (nopLogger) Error(string) {}
+
+func newTestServer(t *testing.T, conf Config) *Server {
+	t.Helper()
+	if conf.AccessLog == nil {
+		conf.AccessLog = io.Discard
+	}
+	logg := logger.New("error", logger.WithOutput(io.Discard))
+	service := internalgrpc.NewServer(logg, app.New(logg, memorystorage.New()), "localhost", 0)
+	s, err := NewServer(nopLogger{}, service, conf)
+	require.NoError(t, err)
+	return s
+}
+
+func serve(s *Server, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestRequestID(t *testing.T) {
+	var got string
+	h := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = reqctx.RequestID(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NotEmpty(t, got)
+	require.Equal(t, got, w.Header().Get(requestIDHeader))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(requestIDHeader, "abc")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.Equal(t, "abc", got)
+	require.Equal(t, "abc", w.Header().Get(requestIDHeader))
+}
+
+func TestServerSettings(t *testing.T) {
+	t.Run("cors", func(t *testing.T) {
+		s := newTestServer(t, Config{})
+
+		r := httptest.NewRequest(http.MethodGet, "/hello", nil)
+		r.Header.Set("Origin", "https://example.com")
+		require.Empty(t, serve(s, r).Header().Get("Access-Control-Allow-Origin"))
+
+		s.SetSettings(Settings{AllowedOrigins: []string{"https://example.com"}})
+		require.Equal(t, "https://example.com", serve(s, r).Header().Get("Access-Control-Allow-Origin"))
+
+		r = httptest.NewRequest(http.MethodOptions, "/hello", nil)
+		r.Header.Set("Origin", "https://example.com")
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := serve(s, r)
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.NotEmpty(t, w.Header().Get("Access-Control-Allow-Methods"))
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		s := newTestServer(t, Config{})
+		for i := 0; i < 10; i++ {
+			require.Equal(t, http.StatusOK, serve(s, httptest.NewRequest(http.MethodGet, "/hello", nil)).Code)
+		}
+
+		s.SetSettings(Settings{RateLimit: 0.001, RateBurst: 2})
+		codes := make([]int, 0, 3)
+		for i := 0; i < 3; i++ {
+			codes = append(codes, serve(s, httptest.NewRequest(http.MethodGet, "/hello", nil)).Code)
+		}
+		require.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+
+		s.SetSettings(Settings{})
+		require.Equal(t, http.StatusOK, serve(s, httptest.NewRequest(http.MethodGet, "/hello", nil)).Code)
+	})
+}
@@ -0,0 +1,101 @@
+package storage
+
+import "fmt"
+
+// AttendeeStatus - ответ участника на приглашение.
+type AttendeeStatus string
+
+const (
+	StatusPending   AttendeeStatus = "pending"
+	StatusAccepted  AttendeeStatus = "accepted"
+	StatusDeclined  AttendeeStatus = "declined"
+	StatusTentative AttendeeStatus = "tentative"
+)
+
+// Valid сообщает, является ли статус одним из известных.
+func (s AttendeeStatus) Valid() bool {
+	switch s {
+	case StatusPending, StatusAccepted, StatusDeclined, StatusTentative:
+		return true
+	}
+	return false
+}
+
+// Attendee - приглашённый на событие пользователь. Владелец события
+// в список участников не входит.
+type Attendee struct {
+	UserID string         `json:"userId"`
+	Status AttendeeStatus `json:"status"`
+}
+
+// Attendee возвращает участника события с ID userID.
+func (e Event) Attendee(userID string) (Attendee, bool) {
+	for _, attendee := range e.Attendees {
+		if attendee.UserID == userID {
+			return attendee, true
+		}
+	}
+	return Attendee{}, false
+}
+
+// Visible сообщает, видно ли событие пользователю: владельцу и приглашённым.
+func (e Event) Visible(userID string) bool {
+	_, ok := e.Attendee(userID)
+	return ok || e.UserID == userID
+}
+
+// KeepStatuses возвращает участников события с ответами, уже данными на приглашение
+// в предыдущей версии события old: менять ответ может только сам участник.
+func (e Event) KeepStatuses(old Event) []Attendee {
+	if len(e.Attendees) == 0 {
+		return nil
+	}
+	attendees := make([]Attendee, 0, len(e.Attendees))
+	for _, attendee := range e.Attendees {
+		if previous, ok := old.Attendee(attendee.UserID); ok {
+			attendee.Status = previous.Status
+		}
+		attendees = append(attendees, attendee)
+	}
+	return attendees
+}
+
+// Occupies сообщает, занимает ли событие время пользователя: владельца и участников,
+// принявших приглашение или ответивших «возможно».
+func (e Event) Occupies(userID string) bool {
+	if e.UserID == userID {
+		return true
+	}
+	attendee, ok := e.Attendee(userID)
+	return ok && (attendee.Status == StatusAccepted || attendee.Status == StatusTentative)
+}
+
+// Recipients возвращает пользователей, которым напоминают о событии:
+// владельца и участников, принявших приглашение.
+func (e Event) Recipients() []string {
+	recipients := []string{e.UserID}
+	for _, attendee := range e.Attendees {
+		if attendee.Status == StatusAccepted {
+			recipients = append(recipients, attendee.UserID)
+		}
+	}
+	return recipients
+}
+
+func (e Event) validateAttendees() error {
+	seen := make(map[string]struct{}, len(e.Attendees))
+	for _, attendee := range e.Attendees {
+		switch _, dup := seen[attendee.UserID]; {
+		case attendee.UserID == "":
+			return fmt.Errorf("%w: empty attendee user id", ErrInvalidEvent)
+		case attendee.UserID == e.UserID:
+			return fmt.Errorf("%w: owner cannot be an attendee", ErrInvalidEvent)
+		case dup:
+			return fmt.Errorf("%w: duplicate attendee %q", ErrInvalidEvent, attendee.UserID)
+		case !attendee.Status.Valid():
+			return fmt.Errorf("%w: invalid attendee status %q", ErrInvalidEvent, attendee.Status)
+		}
+		seen[attendee.UserID] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package storage
+
+import "errors"
+
+var (
+	ErrDateBusy     = errors.New("date is busy by another event")
+	ErrNotFound     = errors.New("event not found")
+	ErrInvalidEvent = errors.New("invalid event")
+	ErrEventExists  = errors.New("event already exists")
+	// ErrVersionConflict возвращается, если событие изменили после того,
+	// как клиент прочитал ожидаемую версию.
+	ErrVersionConflict = errors.New("event version conflict")
+)
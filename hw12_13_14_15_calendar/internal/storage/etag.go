@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ETag возвращает HTTP-тег сущности для версии события.
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ParseETag возвращает версию события из тега, построенного ETag.
+func ParseETag(tag string) (int64, error) {
+	value := strings.TrimSpace(tag)
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return 0, fmt.Errorf("invalid entity tag %q", tag)
+	}
+	version, err := strconv.ParseInt(value[1:len(value)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid entity tag %q", tag)
+	}
+	return version, nil
+}
@@ -1,7 +1,267 @@
 package storage
 
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/recurrence"
+)
+
+// OverlapHorizon ограничивает проверку пересечений бесконечных серий событий:
+// повторения сравниваются на этом отрезке от начала более поздней серии.
+const OverlapHorizon = 4 * 366 * 24 * time.Hour
+
 type Event struct {
-	ID    string
-	Title string
-	// TODO
+	ID string
+	// UID - идентификатор события в iCalendar и имя его ресурса CalDAV. В отличие от ID
+	// он уникален только среди событий владельца: одно приглашение, импортированное
+	// разными пользователями, - это разные события. UID задаётся при создании,
+	// по умолчанию равен ID, и потом не меняется.
+	UID          string
+	Title        string
+	Start        time.Time
+	End          time.Time
+	Description  string
+	UserID       string
+	NotifyBefore time.Duration
+	// RRule - правило повторения RFC 5545, например "FREQ=WEEKLY;BYDAY=MO",
+	// пустая строка означает одиночное событие. Start и End задают первое повторение.
+	RRule string
+	// ExDates - начала повторений, исключённых из серии.
+	ExDates []time.Time
+	// TimeZone - IANA-имя часового пояса, в котором событие создано, например "Europe/Moscow".
+	// Моменты Start и End от него не зависят, а повторения серии разворачиваются
+	// по местному времени этого пояса. Пустая строка означает UTC.
+	TimeZone string
+	// Attendees - приглашённые пользователи и их ответы. Событие видно участникам
+	// в их списках, но занятость владельца проверяется только по его собственным событиям.
+	Attendees []Attendee
+	// Version растёт при каждом изменении события, новое событие получает версию 1.
+	// В Update и Delete это ожидаемая текущая версия, 0 - без проверки.
+	Version int64
+	// DeletedAt - момент, когда событие перенесли в корзину; нулевое значение
+	// у действующих событий.
+	DeletedAt time.Time
+}
+
+// Duration возвращает длительность события.
+func (e Event) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// Recurring сообщает, является ли событие серией повторений.
+func (e Event) Recurring() bool {
+	return e.RRule != ""
+}
+
+// NotifyAt возвращает момент, начиная с которого о событии пора напоминать.
+// Если NotifyBefore равен нулю, напоминание не нужно.
+func (e Event) NotifyAt() time.Time {
+	return e.Start.Add(-e.NotifyBefore)
+}
+
+// Location возвращает часовой пояс события; UTC, если пояс не задан или неизвестен.
+func (e Event) Location() *time.Location {
+	loc, err := LoadLocation(e.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// SeriesEnd возвращает окончание последнего повторения события;
+// ok = false, если серия бесконечна или слишком длинна, чтобы найти её конец.
+func (e Event) SeriesEnd() (end time.Time, ok bool) {
+	if !e.Recurring() {
+		return e.End, true
+	}
+	rule, err := recurrence.Parse(e.RRule)
+	if err != nil {
+		return e.End, true
+	}
+	last, ok := rule.Last(e.localStart())
+	if !ok {
+		return time.Time{}, false
+	}
+	return last.Add(e.Duration()).In(e.Start.Location()), true
+}
+
+// Occurrences возвращает повторения события, пересекающиеся с интервалом [from, to),
+// упорядоченные по времени начала. Для одиночного события это само событие.
+// Повторения вычисляются по местному времени пояса события, поэтому серия
+// «каждый день в 9:00» сохраняет 9:00 и после перехода на летнее время.
+func (e Event) Occurrences(from, to time.Time) []Event {
+	if !e.Recurring() {
+		if e.Start.Before(to) && e.End.After(from) {
+			return []Event{e}
+		}
+		return nil
+	}
+	rule, err := recurrence.Parse(e.RRule)
+	if err != nil {
+		return nil
+	}
+
+	d := e.Duration()
+	var result []Event
+	for _, start := range rule.Between(e.localStart(), from.Add(-d), to) {
+		if e.excluded(start) || !start.Add(d).After(from) {
+			continue
+		}
+		occurrence := e
+		occurrence.Start = start.In(e.Start.Location())
+		occurrence.End = occurrence.Start.Add(d)
+		result = append(result, occurrence)
+	}
+	return result
+}
+
+// NextNotification возвращает ближайшее ещё не начавшееся повторение, о котором пора
+// напомнить в момент now; повторения, начинающиеся не позже notified, уже напомнены.
+func (e Event) NextNotification(now, notified time.Time) (Event, bool) {
+	if e.NotifyBefore <= 0 {
+		return Event{}, false
+	}
+	for _, occurrence := range e.Occurrences(now, now.Add(e.NotifyBefore+time.Nanosecond)) {
+		if occurrence.Start.After(now) && occurrence.Start.After(notified) && !occurrence.NotifyAt().After(now) {
+			return occurrence, true
+		}
+	}
+	return Event{}, false
+}
+
+// Overlaps сообщает, пересекается ли по времени хотя бы одно повторение события
+// с повторением другого события того же владельца.
+func (e Event) Overlaps(other Event) bool {
+	if e.UserID != other.UserID {
+		return false
+	}
+
+	from := e.Start
+	if other.Start.After(from) {
+		from = other.Start
+	}
+	to := from.Add(OverlapHorizon)
+	for _, event := range []Event{e, other} {
+		if end, ok := event.SeriesEnd(); ok && end.Before(to) {
+			to = end
+		}
+	}
+	// Повторение, начавшееся раньше from, может ещё продолжаться.
+	from = from.Add(-maxDuration(e, other))
+
+	a, b := e.Occurrences(from, to), other.Occurrences(from, to)
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		if a[i].Start.Before(b[j].End) && b[j].Start.Before(a[i].End) {
+			return true
+		}
+		if a[i].End.Before(b[j].End) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return false
+}
+
+// Validate проверяет обязательные поля события.
+func (e Event) Validate() error {
+	switch {
+	case e.ID == "":
+		return fmt.Errorf("%w: empty id", ErrInvalidEvent)
+	case e.Title == "":
+		return fmt.Errorf("%w: empty title", ErrInvalidEvent)
+	case e.UserID == "":
+		return fmt.Errorf("%w: empty user id", ErrInvalidEvent)
+	case e.Start.IsZero():
+		return fmt.Errorf("%w: empty start time", ErrInvalidEvent)
+	case !e.End.After(e.Start):
+		return fmt.Errorf("%w: end time must be after start time", ErrInvalidEvent)
+	case e.NotifyBefore < 0:
+		return fmt.Errorf("%w: negative notify before", ErrInvalidEvent)
+	case !e.Recurring() && len(e.ExDates) > 0:
+		return fmt.Errorf("%w: exdates require a recurrence rule", ErrInvalidEvent)
+	}
+	if _, err := LoadLocation(e.TimeZone); err != nil {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidEvent, e.TimeZone)
+	}
+	if err := e.validateAttendees(); err != nil {
+		return err
+	}
+	if e.Recurring() {
+		if _, err := recurrence.Parse(e.RRule); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+		}
+	}
+	return nil
+}
+
+// localStart возвращает начало события по местному времени его пояса.
+func (e Event) localStart() time.Time {
+	return e.Start.In(e.Location())
+}
+
+func (e Event) excluded(start time.Time) bool {
+	for _, exdate := range e.ExDates {
+		if exdate.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+func maxDuration(events ...Event) time.Duration {
+	var d time.Duration
+	for _, event := range events {
+		if event.Duration() > d {
+			d = event.Duration()
+		}
+	}
+	return d
+}
+
+// SortEvents упорядочивает события по времени начала и ID.
+func SortEvents(events []Event) {
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Start.Equal(events[j].Start) {
+			return events[i].ID < events[j].ID
+		}
+		return events[i].Start.Before(events[j].Start)
+	})
+}
+
+// SortDeleted упорядочивает события корзины: сначала удалённые последними, затем по ID.
+func SortDeleted(events []Event) {
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].DeletedAt.Equal(events[j].DeletedAt) {
+			return events[i].ID < events[j].ID
+		}
+		return events[i].DeletedAt.After(events[j].DeletedAt)
+	})
+}
+
+// locations кэширует загруженные часовые пояса: time.LoadLocation каждый раз читает tzdata.
+var locations sync.Map
+
+// LoadLocation возвращает часовой пояс по IANA-имени; пустое имя означает UTC.
+// В отличие от time.LoadLocation, имя "Local" не принимается: пояс сервера
+// не должен влиять на события.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	if name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %s", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
 }
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListFilter - условия отбора событий списка; пустые поля не ограничивают выборку.
+type ListFilter struct {
+	// Title - подстрока названия, регистр не учитывается.
+	Title string
+	// HasReminder, если задан, оставляет только события с напоминанием (true) или без него (false).
+	HasReminder *bool
+	// Attendee оставляет только события, на которые приглашён этот пользователь.
+	Attendee string
+}
+
+// Match сообщает, проходит ли событие фильтр.
+func (f ListFilter) Match(e Event) bool {
+	if f.Title != "" && !strings.Contains(strings.ToLower(e.Title), strings.ToLower(f.Title)) {
+		return false
+	}
+	if f.HasReminder != nil && (e.NotifyBefore > 0) != *f.HasReminder {
+		return false
+	}
+	if f.Attendee != "" {
+		if _, ok := e.Attendee(f.Attendee); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ListOptions - фильтр и страница списка событий, упорядоченного по времени начала и ID.
+type ListOptions struct {
+	ListFilter
+	// After - позиция, после которой начинается страница; нулевое значение - с начала списка.
+	After Cursor
+	// Limit ограничивает число событий страницы, 0 - без ограничения.
+	Limit int
+}
+
+// Page отбирает из упорядоченных SortEvents событий страницу: прошедшие фильтр
+// события после курсора, не больше Limit.
+func (o ListOptions) Page(events []Event) []Event {
+	result := make([]Event, 0, len(events))
+	for _, event := range events {
+		if o.Limit > 0 && len(result) == o.Limit {
+			break
+		}
+		if o.After.Before(event) && o.Match(event) {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+// Cursor - позиция в списке событий: время начала и ID последнего показанного события.
+// Повторения серии различаются временем начала.
+type Cursor struct {
+	Start time.Time
+	ID    string
+}
+
+// CursorOf возвращает курсор, указывающий на событие.
+func CursorOf(e Event) Cursor {
+	return Cursor{Start: e.Start, ID: e.ID}
+}
+
+// IsZero сообщает, что курсор указывает на начало списка.
+func (c Cursor) IsZero() bool {
+	return c.ID == "" && c.Start.IsZero()
+}
+
+// Before сообщает, что событие идёт в списке после курсора.
+func (c Cursor) Before(e Event) bool {
+	if c.IsZero() {
+		return true
+	}
+	if e.Start.Equal(c.Start) {
+		return e.ID > c.ID
+	}
+	return e.Start.After(c.Start)
+}
+
+// String возвращает непрозрачное для клиента представление курсора.
+func (c Cursor) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	raw := strconv.FormatInt(c.Start.UnixNano(), 10) + ":" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor разбирает курсор, построенный Cursor.String; пустая строка - начало списка.
+func ParseCursor(value string) (Cursor, error) {
+	if value == "" {
+		return Cursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", value)
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", value)
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", value)
+	}
+	return Cursor{Start: time.Unix(0, nanos).UTC(), ID: parts[1]}, nil
+}
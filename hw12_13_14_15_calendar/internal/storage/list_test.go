@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	cursor := Cursor{Start: time.Date(2021, time.November, 1, 9, 30, 0, 0, time.UTC), ID: "id:with:colons"}
+
+	parsed, err := ParseCursor(cursor.String())
+	require.NoError(t, err)
+	require.Equal(t, cursor, parsed)
+
+	parsed, err = ParseCursor("")
+	require.NoError(t, err)
+	require.True(t, parsed.IsZero())
+	require.Empty(t, parsed.String())
+
+	for _, value := range []string{"!", "MTIz", "eDppZA"} {
+		_, err := ParseCursor(value)
+		require.Error(t, err, value)
+	}
+
+	require.True(t, cursor.Before(Event{Start: cursor.Start, ID: "z"}))
+	require.False(t, cursor.Before(Event{Start: cursor.Start, ID: cursor.ID}))
+	require.False(t, cursor.Before(Event{Start: cursor.Start.Add(-time.Second), ID: "z"}))
+	require.True(t, Cursor{}.Before(Event{}))
+
+	// ID сравниваются побайтно: заглавные буквы и знаки препинания идут раньше строчных букв.
+	mixed := Cursor{Start: cursor.Start, ID: "Z.ics"}
+	require.True(t, mixed.Before(Event{Start: cursor.Start, ID: "a"}))
+	require.True(t, mixed.Before(Event{Start: cursor.Start, ID: "Z_ics"}))
+	require.False(t, mixed.Before(Event{Start: cursor.Start, ID: "B"}))
+	require.False(t, mixed.Before(Event{Start: cursor.Start, ID: "Z-ics"}))
+}
@@ -0,0 +1,59 @@
+package memorystorage
+
+import (
+	"sort"
+	"time"
+)
+
+type indexItem struct {
+	start time.Time
+	id    string
+}
+
+func (i indexItem) less(other indexItem) bool {
+	if i.start.Equal(other.start) {
+		return i.id < other.id
+	}
+	return i.start.Before(other.start)
+}
+
+// timeIndex - упорядоченный по времени начала (и ID) список событий.
+type timeIndex []indexItem
+
+func (idx timeIndex) search(item indexItem) int {
+	return sort.Search(len(idx), func(i int) bool {
+		return !idx[i].less(item)
+	})
+}
+
+func (idx timeIndex) insert(item indexItem) timeIndex {
+	pos := idx.search(item)
+	idx = append(idx, indexItem{})
+	copy(idx[pos+1:], idx[pos:])
+	idx[pos] = item
+	return idx
+}
+
+func (idx timeIndex) remove(item indexItem) timeIndex {
+	pos := idx.search(item)
+	if pos == len(idx) || idx[pos].id != item.id {
+		return idx
+	}
+	return append(idx[:pos], idx[pos+1:]...)
+}
+
+// between возвращает ID событий, начинающихся в интервале [from, to).
+func (idx timeIndex) between(from, to time.Time) []string {
+	lo := sort.Search(len(idx), func(i int) bool {
+		return !idx[i].start.Before(from)
+	})
+	hi := sort.Search(len(idx), func(i int) bool {
+		return !idx[i].start.Before(to)
+	})
+
+	ids := make([]string, 0, hi-lo)
+	for _, item := range idx[lo:hi] {
+		ids = append(ids, item.id)
+	}
+	return ids
+}
@@ -1,14 +1,504 @@
 package memorystorage
 
-import "sync"
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+)
 
 type Storage struct {
-	// TODO
-	mu sync.RWMutex
+	mu      sync.RWMutex
+	events  map[string]storage.Event
+	byStart timeIndex
+	byOwner map[string]timeIndex
+	// recurring - ID повторяющихся событий по владельцам. Серии не попадают в индексы
+	// по времени начала и разворачиваются в повторения при чтении.
+	recurring map[string]map[string]struct{}
+	// attending - ID событий, на которые приглашён пользователь. Такие события
+	// разворачиваются в повторения при чтении и не участвуют в проверке занятости.
+	attending map[string]map[string]struct{}
+	// trash - удалённые события. Они не попадают в индексы и не занимают время,
+	// но их ID остаются занятыми до окончательного удаления.
+	trash map[string]storage.Event
+	// uids - ID неудалённых событий по владельцам и UID.
+	uids map[string]map[string]string
+	// notified - начало последнего повторения события, уведомление о котором
+	// уже поставлено в очередь.
+	notified map[string]time.Time
+	// Максимальная длительность среди когда-либо сохранённых событий,
+	// позволяет искать по индексу начала события, пересекающиеся с интервалом.
+	maxDuration time.Duration
+	// Аналогично maxNotifyBefore ограничивает поиск событий, о которых пора напомнить.
+	maxNotifyBefore time.Duration
 }
 
 func New() *Storage {
-	return &Storage{}
+	return &Storage{
+		events:    make(map[string]storage.Event),
+		byOwner:   make(map[string]timeIndex),
+		recurring: make(map[string]map[string]struct{}),
+		attending: make(map[string]map[string]struct{}),
+		trash:     make(map[string]storage.Event),
+		uids:      make(map[string]map[string]string),
+		notified:  make(map[string]time.Time),
+	}
+}
+
+func (s *Storage) Connect(ctx context.Context) error {
+	return nil
+}
+
+func (s *Storage) Close(ctx context.Context) error {
+	return nil
+}
+
+func (s *Storage) Create(ctx context.Context, event storage.Event) error {
+	if err := event.Validate(); err != nil {
+		return err
+	}
+	if event.UID == "" {
+		event.UID = event.ID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[event.ID]; ok {
+		return storage.ErrEventExists
+	}
+	if _, ok := s.trash[event.ID]; ok {
+		return storage.ErrEventExists
+	}
+	if _, ok := s.uids[event.UserID][event.UID]; ok {
+		return storage.ErrEventExists
+	}
+	if s.isBusy(event) {
+		return storage.ErrDateBusy
+	}
+	event.Version = 1
+	s.add(event)
+	s.remember(event)
+
+	return nil
+}
+
+func (s *Storage) Update(ctx context.Context, id string, event storage.Event) error {
+	event.ID = id
+	if err := event.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.events[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	if event.Version != 0 && event.Version != old.Version {
+		return storage.ErrVersionConflict
+	}
+	if s.isBusy(event) {
+		return storage.ErrDateBusy
+	}
+	event.UID = old.UID
+	event.Attendees = event.KeepStatuses(old)
+	event.Version = old.Version + 1
+	notified, ok := s.notified[id]
+	s.remove(old)
+	s.add(event)
+	// Перенесённое событие требует нового напоминания. Как и в SQL-хранилище, сменой
+	// часового пояса событие считается перенесённым: от него зависит время повторений.
+	if ok && old.Start.Equal(event.Start) && old.NotifyBefore == event.NotifyBefore && old.RRule == event.RRule &&
+		old.TimeZone == event.TimeZone {
+		s.notified[id] = notified
+	}
+
+	return nil
+}
+
+// Delete переносит в корзину событие id версии version; нулевая версия удаляет любую.
+func (s *Storage) Delete(ctx context.Context, id string, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event, ok := s.events[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	if version != 0 && version != event.Version {
+		return storage.ErrVersionConflict
+	}
+	// Отметка об уведомлении сохраняется, чтобы восстановленное событие не напомнило о себе повторно.
+	notified, ok := s.notified[id]
+	s.remove(event)
+	s.forget(event)
+	if ok {
+		s.notified[id] = notified
+	}
+	event.Version++
+	event.DeletedAt = time.Now().UTC()
+	s.trash[id] = event
+
+	return nil
+}
+
+// Restore возвращает из корзины событие id, принадлежащее userID. Если события
+// нет в корзине или оно чужое, возвращается ErrNotFound, а если его UID занят
+// другим событием владельца - ErrEventExists.
+func (s *Storage) Restore(ctx context.Context, id, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event, ok := s.trash[id]
+	if !ok || event.UserID != userID {
+		return storage.ErrNotFound
+	}
+	// Пока событие было в корзине, его время могли занять.
+	if s.isBusy(event) {
+		return storage.ErrDateBusy
+	}
+	if _, ok := s.uids[event.UserID][event.UID]; ok {
+		return storage.ErrEventExists
+	}
+	delete(s.trash, id)
+	event.Version++
+	event.DeletedAt = time.Time{}
+	s.add(event)
+	s.remember(event)
+
+	return nil
+}
+
+// ListDeleted возвращает события пользователя из корзины, начиная с удалённых последними.
+func (s *Storage) ListDeleted(ctx context.Context, userID string) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]storage.Event, 0)
+	for _, event := range s.trash {
+		if event.UserID == userID {
+			result = append(result, event)
+		}
+	}
+	storage.SortDeleted(result)
+	return result, nil
+}
+
+// ListOwned возвращает неудалённые события владельца userID по времени начала; серии
+// повторений не разворачиваются.
+func (s *Storage) ListOwned(ctx context.Context, userID string) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]storage.Event, 0, len(s.uids[userID]))
+	for _, id := range s.uids[userID] {
+		result = append(result, s.events[id])
+	}
+	storage.SortEvents(result)
+	return result, nil
+}
+
+// DeleteTrashedBefore окончательно удаляет события, перенесённые в корзину раньше before,
+// и возвращает их количество.
+func (s *Storage) DeleteTrashedBefore(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for id, event := range s.trash {
+		if event.DeletedAt.Before(before) {
+			delete(s.trash, id)
+			delete(s.notified, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Storage) Get(ctx context.Context, id string) (storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event, ok := s.events[id]
+	if !ok {
+		return storage.Event{}, storage.ErrNotFound
+	}
+
+	return event, nil
+}
+
+// GetByUID возвращает событие владельца userID с UID uid; события в корзине не возвращаются.
+func (s *Storage) GetByUID(ctx context.Context, userID, uid string) (storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event, ok := s.events[s.uids[userID][uid]]
+	if !ok {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	return event, nil
 }
 
-// TODO
+// ListDay возвращает страницу событий суток, в которые попадает date.
+func (s *Storage) ListDay(
+	ctx context.Context, userID string, date time.Time, opts storage.ListOptions,
+) ([]storage.Event, error) {
+	from, to := storage.DayPeriod(date)
+	return opts.Page(s.list(userID, from, to)), nil
+}
+
+// ListWeek возвращает страницу событий недели, начинающейся в день start.
+func (s *Storage) ListWeek(
+	ctx context.Context, userID string, start time.Time, opts storage.ListOptions,
+) ([]storage.Event, error) {
+	from, to := storage.WeekPeriod(start)
+	return opts.Page(s.list(userID, from, to)), nil
+}
+
+// ListMonth возвращает страницу событий месяца, начинающегося в день start.
+func (s *Storage) ListMonth(
+	ctx context.Context, userID string, start time.Time, opts storage.ListOptions,
+) ([]storage.Event, error) {
+	from, to := storage.MonthPeriod(start)
+	return opts.Page(s.list(userID, from, to)), nil
+}
+
+// ListRange возвращает события пользователя (для серий - повторения),
+// пересекающиеся с интервалом [from, to).
+func (s *Storage) ListRange(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error) {
+	return s.list(userID, from, to), nil
+}
+
+// list возвращает события, которые пользователь создал или на которые приглашён,
+// упорядоченные по времени начала и ID.
+func (s *Storage) list(userID string, from, to time.Time) []storage.Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := s.overlapping(s.byOwner[userID], from, to)
+	if len(s.recurring[userID]) == 0 && len(s.attending[userID]) == 0 {
+		return result
+	}
+	for id := range s.recurring[userID] {
+		result = append(result, s.events[id].Occurrences(from, to)...)
+	}
+	for id := range s.attending[userID] {
+		result = append(result, s.events[id].Occurrences(from, to)...)
+	}
+	storage.SortEvents(result)
+	return result
+}
+
+// SetAttendeeStatus сохраняет ответ участника userID на приглашение на событие id.
+// Если события нет или пользователь на него не приглашён, возвращается ErrNotFound.
+func (s *Storage) SetAttendeeStatus(
+	ctx context.Context, id, userID string, status storage.AttendeeStatus,
+) error {
+	if !status.Valid() {
+		return fmt.Errorf("%w: invalid attendee status %q", storage.ErrInvalidEvent, status)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event, ok := s.events[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	for i, attendee := range event.Attendees {
+		if attendee.UserID == userID {
+			// Прочитанные ранее копии события разделяют с хранилищем список участников,
+			// поэтому он не меняется на месте.
+			event.Attendees = append([]storage.Attendee(nil), event.Attendees...)
+			event.Attendees[i].Status = status
+			event.Version++
+			s.events[id] = event
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+// ListNotifyDue возвращает ещё не начавшиеся события (для серий - ближайшие повторения),
+// для которых наступило время уведомления, но уведомление ещё не отправлялось.
+func (s *Storage) ListNotifyDue(ctx context.Context, now time.Time) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.byStart.between(now, now.Add(s.maxNotifyBefore+time.Nanosecond))
+	for _, series := range s.recurring {
+		for id := range series {
+			ids = append(ids, id)
+		}
+	}
+
+	result := make([]storage.Event, 0)
+	for _, id := range ids {
+		if occurrence, ok := s.events[id].NextNotification(now, s.notified[id]); ok {
+			result = append(result, occurrence)
+		}
+	}
+	storage.SortEvents(result)
+	return result, nil
+}
+
+// MarkNotified запоминает, что уведомление о повторении события, начинающемся в occurrence,
+// поставлено в очередь. Нулевое occurrence снимает отметку.
+func (s *Storage) MarkNotified(ctx context.Context, id string, occurrence time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[id]; !ok {
+		return storage.ErrNotFound
+	}
+	if occurrence.IsZero() {
+		delete(s.notified, id)
+	} else {
+		s.notified[id] = occurrence
+	}
+	return nil
+}
+
+// DeleteEndedBefore удаляет события, закончившиеся раньше before, и возвращает их количество.
+// Серия удаляется, когда закончилось её последнее повторение.
+func (s *Storage) DeleteEndedBefore(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ended []storage.Event
+	for _, id := range s.byStart.between(time.Time{}, before) {
+		if event := s.events[id]; event.End.Before(before) {
+			ended = append(ended, event)
+		}
+	}
+	for _, series := range s.recurring {
+		for id := range series {
+			event := s.events[id]
+			if end, ok := event.SeriesEnd(); ok && end.Before(before) {
+				ended = append(ended, event)
+			}
+		}
+	}
+	for _, event := range ended {
+		s.remove(event)
+		s.forget(event)
+	}
+	deleted := len(ended)
+	for id, event := range s.trash {
+		if end, ok := event.SeriesEnd(); ok && end.Before(before) {
+			delete(s.trash, id)
+			delete(s.notified, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// overlapping выбирает из индекса события, пересекающиеся с интервалом [from, to).
+func (s *Storage) overlapping(idx timeIndex, from, to time.Time) []storage.Event {
+	result := make([]storage.Event, 0)
+	for _, id := range idx.between(from.Add(-s.maxDuration), to) {
+		event := s.events[id]
+		if event.End.After(from) {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+func (s *Storage) isBusy(event storage.Event) bool {
+	end, ok := event.SeriesEnd()
+	if !ok {
+		end = event.Start.Add(storage.OverlapHorizon)
+	}
+	candidates := s.overlapping(s.byOwner[event.UserID], event.Start, end)
+	for id := range s.recurring[event.UserID] {
+		candidates = append(candidates, s.events[id])
+	}
+
+	for _, other := range candidates {
+		if other.ID != event.ID && event.Overlaps(other) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Storage) add(event storage.Event) {
+	if len(event.ExDates) > 0 {
+		event.ExDates = append([]time.Time(nil), event.ExDates...)
+	}
+	if len(event.Attendees) > 0 {
+		event.Attendees = append([]storage.Attendee(nil), event.Attendees...)
+	}
+	for _, attendee := range event.Attendees {
+		if s.attending[attendee.UserID] == nil {
+			s.attending[attendee.UserID] = make(map[string]struct{})
+		}
+		s.attending[attendee.UserID][event.ID] = struct{}{}
+	}
+	s.events[event.ID] = event
+	if event.NotifyBefore > s.maxNotifyBefore {
+		s.maxNotifyBefore = event.NotifyBefore
+	}
+	if event.Recurring() {
+		if s.recurring[event.UserID] == nil {
+			s.recurring[event.UserID] = make(map[string]struct{})
+		}
+		s.recurring[event.UserID][event.ID] = struct{}{}
+		return
+	}
+
+	item := indexItem{start: event.Start, id: event.ID}
+	s.byStart = s.byStart.insert(item)
+	s.byOwner[event.UserID] = s.byOwner[event.UserID].insert(item)
+	if d := event.Duration(); d > s.maxDuration {
+		s.maxDuration = d
+	}
+}
+
+func (s *Storage) remove(event storage.Event) {
+	delete(s.events, event.ID)
+	delete(s.notified, event.ID)
+	for _, attendee := range event.Attendees {
+		delete(s.attending[attendee.UserID], event.ID)
+		if len(s.attending[attendee.UserID]) == 0 {
+			delete(s.attending, attendee.UserID)
+		}
+	}
+	if event.Recurring() {
+		delete(s.recurring[event.UserID], event.ID)
+		if len(s.recurring[event.UserID]) == 0 {
+			delete(s.recurring, event.UserID)
+		}
+		return
+	}
+
+	item := indexItem{start: event.Start, id: event.ID}
+	s.byStart = s.byStart.remove(item)
+	if idx := s.byOwner[event.UserID].remove(item); len(idx) > 0 {
+		s.byOwner[event.UserID] = idx
+	} else {
+		delete(s.byOwner, event.UserID)
+	}
+}
+
+// remember занимает UID события его владельца.
+func (s *Storage) remember(event storage.Event) {
+	if s.uids[event.UserID] == nil {
+		s.uids[event.UserID] = make(map[string]string)
+	}
+	s.uids[event.UserID][event.UID] = event.ID
+}
+
+// forget освобождает UID удалённого события.
+func (s *Storage) forget(event storage.Event) {
+	delete(s.uids[event.UserID], event.UID)
+	if len(s.uids[event.UserID]) == 0 {
+		delete(s.uids, event.UserID)
+	}
+}
@@ -1,7 +1,519 @@
 package memorystorage
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+var baseTime = time.Date(2021, time.September, 6, 10, 0, 0, 0, time.UTC)
+
+func newEvent(id, userID string, start time.Time, d time.Duration) storage.Event {
+	return storage.Event{
+		ID:     id,
+		Title:  "event " + id,
+		Start:  start,
+		End:    start.Add(d),
+		UserID: userID,
+	}
+}
 
 func TestStorage(t *testing.T) {
-	// TODO
+	ctx := context.Background()
+
+	t.Run("crud", func(t *testing.T) {
+		s := New()
+		event := newEvent("1", "user", baseTime, time.Hour)
+
+		require.NoError(t, s.Create(ctx, event))
+		got, err := s.Get(ctx, "1")
+		require.NoError(t, err)
+		// Без UID событие получает UID, равный ID.
+		event.UID = "1"
+		event.Version = 1
+		require.Equal(t, event, got)
+
+		event.Title = "updated"
+		event.Start = baseTime.Add(2 * time.Hour)
+		event.End = baseTime.Add(3 * time.Hour)
+		require.NoError(t, s.Update(ctx, "1", event))
+		got, err = s.Get(ctx, "1")
+		require.NoError(t, err)
+		event.Version = 2
+		require.Equal(t, event, got)
+
+		require.NoError(t, s.Delete(ctx, "1", 0))
+		_, err = s.Get(ctx, "1")
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("business errors", func(t *testing.T) {
+		s := New()
+		require.NoError(t, s.Create(ctx, newEvent("1", "user", baseTime, time.Hour)))
+
+		err := s.Create(ctx, newEvent("1", "user", baseTime.Add(5*time.Hour), time.Hour))
+		require.ErrorIs(t, err, storage.ErrEventExists)
+
+		err = s.Create(ctx, newEvent("2", "user", baseTime.Add(30*time.Minute), time.Hour))
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+
+		err = s.Create(ctx, newEvent("3", "user", baseTime, -time.Hour))
+		require.ErrorIs(t, err, storage.ErrInvalidEvent)
+
+		err = s.Update(ctx, "4", newEvent("4", "user", baseTime, time.Hour))
+		require.ErrorIs(t, err, storage.ErrNotFound)
+
+		require.ErrorIs(t, s.Delete(ctx, "4", 0), storage.ErrNotFound)
+	})
+
+	t.Run("uid", func(t *testing.T) {
+		s := New()
+		// Одно приглашение, импортированное двумя пользователями, - два разных события.
+		invite := newEvent("1", "alice", baseTime, time.Hour)
+		invite.UID = "040000008200E00074C5B7101A82E008@outlook.com"
+		require.NoError(t, s.Create(ctx, invite))
+		other := newEvent("2", "bob", baseTime, time.Hour)
+		other.UID = invite.UID
+		require.NoError(t, s.Create(ctx, other))
+
+		duplicate := newEvent("3", "alice", baseTime.Add(2*time.Hour), time.Hour)
+		duplicate.UID = invite.UID
+		require.ErrorIs(t, s.Create(ctx, duplicate), storage.ErrEventExists)
+
+		got, err := s.GetByUID(ctx, "bob", invite.UID)
+		require.NoError(t, err)
+		require.Equal(t, "2", got.ID)
+		_, err = s.GetByUID(ctx, "carol", invite.UID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+
+		// UID не меняется при изменении события.
+		invite.UID = "changed"
+		require.NoError(t, s.Update(ctx, "1", invite))
+		got, err = s.GetByUID(ctx, "alice", other.UID)
+		require.NoError(t, err)
+		require.Equal(t, "1", got.ID)
+
+		// Удалённое событие освобождает UID, а восстановить его можно, только пока UID свободен.
+		require.NoError(t, s.Delete(ctx, "1", 0))
+		_, err = s.GetByUID(ctx, "alice", other.UID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+		require.NoError(t, s.Create(ctx, duplicate))
+		require.ErrorIs(t, s.Restore(ctx, "1", "alice"), storage.ErrEventExists)
+		require.NoError(t, s.Delete(ctx, "3", 0))
+		require.NoError(t, s.Restore(ctx, "1", "alice"))
+	})
+
+	t.Run("list owned", func(t *testing.T) {
+		s := New()
+		series := newEvent("series", "user", baseTime, time.Hour)
+		series.RRule = "FREQ=DAILY"
+		require.NoError(t, s.Create(ctx, series))
+		require.NoError(t, s.Create(ctx, newEvent("single", "user", baseTime.Add(-2*time.Hour), time.Hour)))
+		require.NoError(t, s.Create(ctx, newEvent("trashed", "user", baseTime.Add(2*time.Hour), time.Hour)))
+		require.NoError(t, s.Delete(ctx, "trashed", 0))
+		invite := newEvent("invite", "other", baseTime.Add(4*time.Hour), time.Hour)
+		invite.Attendees = []storage.Attendee{{UserID: "user", Status: storage.StatusPending}}
+		require.NoError(t, s.Create(ctx, invite))
+
+		// Серия возвращается один раз, без разворачивания в повторения.
+		events, err := s.ListOwned(ctx, "user")
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		require.Equal(t, "single", events[0].ID)
+		require.Equal(t, "series", events[1].ID)
+		require.Equal(t, "FREQ=DAILY", events[1].RRule)
+		require.True(t, events[1].Start.Equal(baseTime))
+
+		events, err = s.ListOwned(ctx, "nobody")
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+
+	t.Run("version conflict", func(t *testing.T) {
+		s := New()
+		event := newEvent("1", "user", baseTime, time.Hour)
+		require.NoError(t, s.Create(ctx, event))
+
+		event.Version = 1
+		require.NoError(t, s.Update(ctx, "1", event))
+		// Второй клиент прочитал ту же версию 1 и опоздал.
+		event.Title = "stale"
+		require.ErrorIs(t, s.Update(ctx, "1", event), storage.ErrVersionConflict)
+		require.ErrorIs(t, s.Delete(ctx, "1", 1), storage.ErrVersionConflict)
+
+		// Ответ участника тоже меняет версию.
+		event.Title, event.Version = "updated", 2
+		event.Attendees = []storage.Attendee{{UserID: "bob", Status: storage.StatusPending}}
+		require.NoError(t, s.Update(ctx, "1", event))
+		require.NoError(t, s.SetAttendeeStatus(ctx, "1", "bob", storage.StatusAccepted))
+		got, err := s.Get(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, int64(4), got.Version)
+		require.Equal(t, "updated", got.Title)
+
+		require.NoError(t, s.Delete(ctx, "1", 4))
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		s := New()
+		require.NoError(t, s.Create(ctx, newEvent("1", "user", baseTime, time.Hour)))
+		require.NoError(t, s.Delete(ctx, "1", 1))
+
+		_, err := s.Get(ctx, "1")
+		require.ErrorIs(t, err, storage.ErrNotFound)
+		require.ErrorIs(t, s.Delete(ctx, "1", 0), storage.ErrNotFound)
+		events, err := s.ListDay(ctx, "user", baseTime, storage.ListOptions{})
+		require.NoError(t, err)
+		require.Empty(t, events)
+		trash, err := s.ListDeleted(ctx, "user")
+		require.NoError(t, err)
+		require.Len(t, trash, 1)
+		require.Equal(t, int64(2), trash[0].Version)
+		require.False(t, trash[0].DeletedAt.IsZero())
+
+		// ID удалённого события занят, а его время - свободно.
+		require.ErrorIs(t, s.Create(ctx, newEvent("1", "user", baseTime.Add(5*time.Hour), time.Hour)),
+			storage.ErrEventExists)
+		require.NoError(t, s.Create(ctx, newEvent("2", "user", baseTime, time.Hour)))
+		require.ErrorIs(t, s.Restore(ctx, "1", "user"), storage.ErrDateBusy)
+		require.NoError(t, s.Delete(ctx, "2", 0))
+
+		require.ErrorIs(t, s.Restore(ctx, "1", "other"), storage.ErrNotFound)
+		require.NoError(t, s.Restore(ctx, "1", "user"))
+		got, err := s.Get(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, int64(3), got.Version)
+		require.True(t, got.DeletedAt.IsZero())
+		require.ErrorIs(t, s.Restore(ctx, "1", "user"), storage.ErrNotFound)
+
+		n, err := s.DeleteTrashedBefore(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		trash, err = s.ListDeleted(ctx, "user")
+		require.NoError(t, err)
+		require.Empty(t, trash)
+	})
+
+	t.Run("date busy", func(t *testing.T) {
+		s := New()
+		require.NoError(t, s.Create(ctx, newEvent("1", "user", baseTime, time.Hour)))
+
+		// Вплотную к существующему событию.
+		require.NoError(t, s.Create(ctx, newEvent("2", "user", baseTime.Add(time.Hour), time.Hour)))
+		require.NoError(t, s.Create(ctx, newEvent("3", "user", baseTime.Add(-time.Hour), time.Hour)))
+		// То же время у другого пользователя.
+		require.NoError(t, s.Create(ctx, newEvent("4", "other", baseTime, time.Hour)))
+
+		// Длинное событие накрывает существующие.
+		err := s.Create(ctx, newEvent("5", "user", baseTime.Add(-24*time.Hour), 48*time.Hour))
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+		// Короткое событие внутри существующего.
+		err = s.Create(ctx, newEvent("6", "user", baseTime.Add(10*time.Minute), 10*time.Minute))
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+
+		// Перенос события на своё же время не считается пересечением.
+		require.NoError(t, s.Update(ctx, "1", newEvent("1", "user", baseTime.Add(10*time.Minute), 50*time.Minute)))
+		err = s.Update(ctx, "1", newEvent("1", "user", baseTime.Add(30*time.Minute), time.Hour))
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		s := New()
+		day := time.Date(2021, time.September, 6, 0, 0, 0, 0, time.UTC)
+		require.NoError(t, s.Create(ctx, newEvent("long", "user", day.Add(-48*time.Hour), 49*time.Hour)))
+		require.NoError(t, s.Create(ctx, newEvent("day", "user", day.Add(10*time.Hour), time.Hour)))
+		require.NoError(t, s.Create(ctx, newEvent("week", "user", day.AddDate(0, 0, 3), time.Hour)))
+		require.NoError(t, s.Create(ctx, newEvent("month", "user", day.AddDate(0, 0, 20), time.Hour)))
+		require.NoError(t, s.Create(ctx, newEvent("next", "user", day.AddDate(0, 1, 0), time.Hour)))
+
+		events, err := s.ListDay(ctx, "user", day.Add(15*time.Hour), storage.ListOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"long", "day"}, ids(events))
+
+		events, err = s.ListWeek(ctx, "user", day, storage.ListOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"long", "day", "week"}, ids(events))
+
+		events, err = s.ListMonth(ctx, "user", day, storage.ListOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"long", "day", "week", "month"}, ids(events))
+
+		events, err = s.ListDay(ctx, "user", day.AddDate(0, 0, 1), storage.ListOptions{})
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		// События других пользователей в выборку не попадают.
+		require.NoError(t, s.Create(ctx, newEvent("other", "other", day.Add(10*time.Hour), time.Hour)))
+		events, err = s.ListDay(ctx, "user", day, storage.ListOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"long", "day"}, ids(events))
+		events, err = s.ListDay(ctx, "other", day, storage.ListOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"other"}, ids(events))
+	})
+
+	t.Run("notify due", func(t *testing.T) {
+		s := New()
+		withNotify := func(event storage.Event, d time.Duration) storage.Event {
+			event.NotifyBefore = d
+			return event
+		}
+		require.NoError(t, s.Create(ctx, withNotify(newEvent("due", "user", baseTime, time.Hour), 15*time.Minute)))
+		require.NoError(t, s.Create(ctx, withNotify(newEvent("early", "user", baseTime.Add(2*time.Hour), time.Hour), 15*time.Minute)))
+		require.NoError(t, s.Create(ctx, withNotify(newEvent("started", "other", baseTime.Add(-time.Hour), 2*time.Hour), time.Hour)))
+		require.NoError(t, s.Create(ctx, newEvent("silent", "third", baseTime, time.Hour)))
+
+		now := baseTime.Add(-10 * time.Minute)
+		events, err := s.ListNotifyDue(ctx, now)
+		require.NoError(t, err)
+		require.Equal(t, []string{"due"}, ids(events))
+
+		require.NoError(t, s.MarkNotified(ctx, "due", baseTime))
+		events, err = s.ListNotifyDue(ctx, now)
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		// Изменение описания не требует нового напоминания, а перенос - требует.
+		due := withNotify(newEvent("due", "user", baseTime, time.Hour), 15*time.Minute)
+		due.Description = "agenda"
+		require.NoError(t, s.Update(ctx, "due", due))
+		events, err = s.ListNotifyDue(ctx, now)
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		due.TimeZone = "Europe/Berlin"
+		require.NoError(t, s.Update(ctx, "due", due))
+		events, err = s.ListNotifyDue(ctx, now)
+		require.NoError(t, err)
+		require.Equal(t, []string{"due"}, ids(events))
+
+		require.NoError(t, s.MarkNotified(ctx, "due", baseTime))
+		due.Start = baseTime.Add(time.Minute)
+		require.NoError(t, s.Update(ctx, "due", due))
+		events, err = s.ListNotifyDue(ctx, now)
+		require.NoError(t, err)
+		require.Equal(t, []string{"due"}, ids(events))
+
+		require.ErrorIs(t, s.MarkNotified(ctx, "missing", baseTime), storage.ErrNotFound)
+	})
+
+	t.Run("recurring", func(t *testing.T) {
+		s := New()
+		// Ежедневный созвон в 10:00 по будням, кроме среды 8 сентября.
+		standup := newEvent("standup", "user", baseTime, 15*time.Minute)
+		standup.RRule = "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR"
+		standup.ExDates = []time.Time{baseTime.AddDate(0, 0, 2)}
+		standup.NotifyBefore = 10 * time.Minute
+		require.NoError(t, s.Create(ctx, standup))
+		require.NoError(t, s.Create(ctx, newEvent("lunch", "user", baseTime.AddDate(0, 0, 1).Add(2*time.Hour), time.Hour)))
+
+		events, err := s.ListWeek(ctx, "user", baseTime, storage.ListOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"standup", "standup", "lunch", "standup", "standup"}, ids(events))
+		require.Equal(t, baseTime.AddDate(0, 0, 3), events[3].Start)
+		require.Equal(t, baseTime.AddDate(0, 0, 3).Add(15*time.Minute), events[3].End)
+
+		events, err = s.ListDay(ctx, "user", baseTime.AddDate(0, 0, 2), storage.ListOptions{})
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		// Пересечение с любым повторением серии, но не с исключённым.
+		err = s.Create(ctx, newEvent("busy", "user", baseTime.AddDate(0, 1, 0).Add(5*time.Minute), time.Hour))
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+		require.NoError(t, s.Create(ctx, newEvent("free", "user", baseTime.AddDate(0, 0, 2), time.Hour)))
+		weekly := newEvent("weekly", "user", baseTime.AddDate(0, 0, 5), time.Hour)
+		weekly.RRule = "FREQ=WEEKLY"
+		require.NoError(t, s.Create(ctx, weekly))
+		weekly.RRule = "FREQ=DAILY"
+		require.ErrorIs(t, s.Update(ctx, "weekly", weekly), storage.ErrDateBusy)
+
+		// Напоминания приходят о каждом повторении.
+		now := baseTime.AddDate(0, 0, 1).Add(-5 * time.Minute)
+		events, err = s.ListNotifyDue(ctx, now)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, baseTime.AddDate(0, 0, 1), events[0].Start)
+		require.NoError(t, s.MarkNotified(ctx, "standup", events[0].Start))
+		events, err = s.ListNotifyDue(ctx, now)
+		require.NoError(t, err)
+		require.Empty(t, events)
+		events, err = s.ListNotifyDue(ctx, now.AddDate(0, 0, 2))
+		require.NoError(t, err)
+		require.Equal(t, []string{"standup"}, ids(events))
+
+		// Бесконечные серии не удаляются как устаревшие.
+		deleted, err := s.DeleteEndedBefore(ctx, baseTime.AddDate(1, 0, 0))
+		require.NoError(t, err)
+		require.Equal(t, 2, deleted)
+		_, err = s.Get(ctx, "standup")
+		require.NoError(t, err)
+	})
+
+	t.Run("attendees", func(t *testing.T) {
+		s := New()
+		event := newEvent("1", "owner", baseTime, time.Hour)
+		event.Attendees = []storage.Attendee{
+			{UserID: "bob", Status: storage.StatusPending},
+			{UserID: "carol", Status: storage.StatusPending},
+		}
+		require.NoError(t, s.Create(ctx, event))
+		// Приглашение не занимает время участника.
+		require.NoError(t, s.Create(ctx, newEvent("2", "bob", baseTime, time.Hour)))
+
+		events, err := s.ListDay(ctx, "bob", baseTime, storage.ListOptions{})
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		events, err = s.ListDay(ctx, "dave", baseTime, storage.ListOptions{})
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		got, err := s.Get(ctx, "1")
+		require.NoError(t, err)
+		require.NoError(t, s.SetAttendeeStatus(ctx, "1", "bob", storage.StatusAccepted))
+		require.ErrorIs(t, s.SetAttendeeStatus(ctx, "1", "dave", storage.StatusAccepted), storage.ErrNotFound)
+		require.ErrorIs(t, s.SetAttendeeStatus(ctx, "3", "bob", storage.StatusAccepted), storage.ErrNotFound)
+		// Прочитанная ранее копия не меняется.
+		require.Equal(t, storage.StatusPending, got.Attendees[0].Status)
+
+		// Владелец не может поменять ответ участника, а исключённый участник больше не видит событие.
+		event.Attendees = []storage.Attendee{{UserID: "bob", Status: storage.StatusDeclined}}
+		require.NoError(t, s.Update(ctx, "1", event))
+		got, err = s.Get(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, []storage.Attendee{{UserID: "bob", Status: storage.StatusAccepted}}, got.Attendees)
+		events, err = s.ListDay(ctx, "carol", baseTime, storage.ListOptions{})
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		event.Attendees = []storage.Attendee{{UserID: "owner", Status: storage.StatusPending}}
+		require.ErrorIs(t, s.Update(ctx, "1", event), storage.ErrInvalidEvent)
+	})
+
+	t.Run("list page", func(t *testing.T) {
+		s := New()
+		daily := newEvent("daily", "user", baseTime.Add(-2*time.Hour), 30*time.Minute)
+		daily.RRule = "FREQ=DAILY"
+		daily.NotifyBefore = 10 * time.Minute
+		require.NoError(t, s.Create(ctx, daily))
+		invited := newEvent("invited", "owner", baseTime, time.Hour)
+		invited.Attendees = []storage.Attendee{
+			{UserID: "user", Status: storage.StatusPending},
+			{UserID: "bob", Status: storage.StatusPending},
+		}
+		require.NoError(t, s.Create(ctx, invited))
+		require.NoError(t, s.Create(ctx, newEvent("b", "user", baseTime, time.Hour)))
+		require.NoError(t, s.Create(ctx, newEvent("a", "user", baseTime.Add(-time.Hour), 30*time.Minute)))
+
+		// Страницы идут по времени начала и ID, повторения серии - отдельные позиции.
+		var ids []string
+		opts := storage.ListOptions{Limit: 2}
+		for {
+			page, err := s.ListWeek(ctx, "user", baseTime, opts)
+			require.NoError(t, err)
+			if len(page) == 0 {
+				break
+			}
+			require.LessOrEqual(t, len(page), 2)
+			for _, event := range page {
+				ids = append(ids, event.ID)
+			}
+			opts.After = storage.CursorOf(page[len(page)-1])
+		}
+		require.Equal(t, []string{"daily", "a", "b", "invited", "daily", "daily", "daily", "daily", "daily", "daily"}, ids)
+
+		reminds, silent := true, false
+		filters := []struct {
+			filter storage.ListFilter
+			ids    []string
+		}{
+			{storage.ListFilter{Title: "EVENT "}, []string{"daily", "a", "b", "invited"}},
+			{storage.ListFilter{Title: "dai", HasReminder: &reminds}, []string{"daily"}},
+			{storage.ListFilter{HasReminder: &silent}, []string{"a", "b", "invited"}},
+			{storage.ListFilter{Attendee: "bob"}, []string{"invited"}},
+		}
+		for _, tc := range filters {
+			events, err := s.ListDay(ctx, "user", baseTime, storage.ListOptions{ListFilter: tc.filter})
+			require.NoError(t, err)
+			ids = ids[:0]
+			for _, event := range events {
+				ids = append(ids, event.ID)
+			}
+			require.Equal(t, tc.ids, ids)
+		}
+	})
+
+	t.Run("delete ended before", func(t *testing.T) {
+		s := New()
+		require.NoError(t, s.Create(ctx, newEvent("old", "user", baseTime.AddDate(-2, 0, 0), time.Hour)))
+		require.NoError(t, s.Create(ctx, newEvent("long", "user", baseTime.AddDate(-2, 0, 1), 400*24*time.Hour)))
+		require.NoError(t, s.Create(ctx, newEvent("recent", "user", baseTime.AddDate(0, -1, 0), time.Hour)))
+
+		deleted, err := s.DeleteEndedBefore(ctx, baseTime.AddDate(-1, 0, 0))
+		require.NoError(t, err)
+		require.Equal(t, 1, deleted)
+
+		_, err = s.Get(ctx, "old")
+		require.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = s.Get(ctx, "long")
+		require.NoError(t, err)
+	})
+
+	t.Run("concurrency", func(t *testing.T) {
+		s := New()
+		const (
+			users   = 10
+			perUser = 100
+		)
+
+		var wg sync.WaitGroup
+		wg.Add(users * 3)
+		for u := 0; u < users; u++ {
+			userID := fmt.Sprintf("user%d", u)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < perUser; i++ {
+					id := fmt.Sprintf("%s-%d", userID, i)
+					require.NoError(t, s.Create(ctx, newEvent(id, userID, baseTime.Add(time.Duration(i)*time.Hour), time.Hour)))
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				// Все события конкурируют за одно и то же время.
+				for i := 0; i < perUser; i++ {
+					id := fmt.Sprintf("%s-busy-%d", userID, i)
+					err := s.Create(ctx, newEvent(id, userID, baseTime.Add(-time.Hour), 30*time.Minute))
+					if err != nil {
+						require.ErrorIs(t, err, storage.ErrDateBusy)
+					}
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for i := 0; i < perUser; i++ {
+					_, err := s.ListWeek(ctx, userID, baseTime, storage.ListOptions{})
+					require.NoError(t, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		for u := 0; u < users; u++ {
+			events, err := s.ListMonth(ctx, fmt.Sprintf("user%d", u), baseTime.Add(-24*time.Hour), storage.ListOptions{})
+			require.NoError(t, err)
+			require.Len(t, events, perUser+1)
+		}
+	})
+}
+
+func ids(events []storage.Event) []string {
+	result := make([]string, 0, len(events))
+	for _, event := range events {
+		result = append(result, event.ID)
+	}
+	return result
 }
@@ -0,0 +1,42 @@
+package storage
+
+import "time"
+
+// Notification - напоминание о событии. В хранилище не сохраняется,
+// а передаётся через очередь от планировщика к рассыльщику.
+type Notification struct {
+	EventID string    `json:"eventId"`
+	Title   string    `json:"title"`
+	Date    time.Time `json:"date"`
+	UserID  string    `json:"userId"`
+	// RequestID - ID запуска планировщика, поставившего напоминание в очередь.
+	// Рассыльщик пишет его в свои логи, связывая их с логами планировщика.
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// NewNotification формирует напоминание о событии.
+func NewNotification(event Event) Notification {
+	return Notification{
+		EventID: event.ID,
+		Title:   event.Title,
+		Date:    event.Start,
+		UserID:  event.UserID,
+	}
+}
+
+// Статусы доставки уведомления.
+const (
+	NotificationSent   = "sent"
+	NotificationFailed = "failed"
+)
+
+// NotificationStatus - отчёт рассыльщика о попытке доставить уведомление.
+type NotificationStatus struct {
+	EventID string    `json:"eventId"`
+	UserID  string    `json:"userId"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+	// RequestID - ID запроса из доставленного уведомления.
+	RequestID string `json:"requestId,omitempty"`
+}
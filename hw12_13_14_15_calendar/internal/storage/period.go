@@ -0,0 +1,24 @@
+package storage
+
+import "time"
+
+// Границы периодов вычисляются по местному времени date.Location(), поэтому сутки
+// перехода на летнее или зимнее время длятся 23 или 25 часов.
+
+// DayPeriod возвращает границы суток [from, to), в которые попадает date.
+func DayPeriod(date time.Time) (from, to time.Time) {
+	from = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	return from, from.AddDate(0, 0, 1)
+}
+
+// WeekPeriod возвращает границы недели [from, to), начинающейся в день start.
+func WeekPeriod(start time.Time) (from, to time.Time) {
+	from, _ = DayPeriod(start)
+	return from, from.AddDate(0, 0, 7)
+}
+
+// MonthPeriod возвращает границы месяца [from, to), начинающегося в день start.
+func MonthPeriod(start time.Time) (from, to time.Time) {
+	from, _ = DayPeriod(start)
+	return from, from.AddDate(0, 1, 0)
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := LoadLocation(name)
+	require.NoError(t, err)
+	return loc
+}
+
+func TestPeriods(t *testing.T) {
+	newYork := loadLocation(t, "America/New_York")
+	berlin := loadLocation(t, "Europe/Berlin")
+	vladivostok := loadLocation(t, "Asia/Vladivostok")
+
+	tests := []struct {
+		name     string
+		period   func(time.Time) (time.Time, time.Time)
+		date     time.Time
+		from     time.Time
+		duration time.Duration
+	}{
+		{
+			name: "utc day", period: DayPeriod,
+			date:     time.Date(2021, time.March, 14, 15, 0, 0, 0, time.UTC),
+			from:     time.Date(2021, time.March, 14, 0, 0, 0, 0, time.UTC),
+			duration: 24 * time.Hour,
+		},
+		{
+			name: "same instant in vladivostok", period: DayPeriod,
+			date:     time.Date(2021, time.March, 14, 15, 0, 0, 0, time.UTC).In(vladivostok),
+			from:     time.Date(2021, time.March, 14, 14, 0, 0, 0, time.UTC),
+			duration: 24 * time.Hour,
+		},
+		{
+			name: "spring forward day", period: DayPeriod,
+			date:     time.Date(2021, time.March, 14, 12, 0, 0, 0, newYork),
+			from:     time.Date(2021, time.March, 14, 5, 0, 0, 0, time.UTC),
+			duration: 23 * time.Hour,
+		},
+		{
+			name: "fall back day", period: DayPeriod,
+			date:     time.Date(2021, time.November, 7, 23, 59, 0, 0, newYork),
+			from:     time.Date(2021, time.November, 7, 4, 0, 0, 0, time.UTC),
+			duration: 25 * time.Hour,
+		},
+		{
+			name: "week over spring forward", period: WeekPeriod,
+			date:     time.Date(2021, time.March, 22, 9, 0, 0, 0, berlin),
+			from:     time.Date(2021, time.March, 21, 23, 0, 0, 0, time.UTC),
+			duration: 7*24*time.Hour - time.Hour,
+		},
+		{
+			name: "month over fall back", period: MonthPeriod,
+			date:     time.Date(2021, time.October, 1, 0, 0, 0, 0, berlin),
+			from:     time.Date(2021, time.September, 30, 22, 0, 0, 0, time.UTC),
+			duration: 31*24*time.Hour + time.Hour,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			from, to := tc.period(tc.date)
+			require.True(t, tc.from.Equal(from), "from %s", from)
+			require.Equal(t, tc.duration, to.Sub(from))
+			require.Zero(t, to.Hour(), "to is not a local midnight: %s", to)
+		})
+	}
+}
+
+func TestOccurrencesTimeZone(t *testing.T) {
+	berlin := loadLocation(t, "Europe/Berlin")
+	// Еженедельно по понедельникам в 9:00 по Берлину, моменты хранятся в UTC.
+	event := Event{
+		ID: "1", Title: "planning", UserID: "user", RRule: "FREQ=WEEKLY;COUNT=3",
+		Start:    time.Date(2021, time.March, 22, 8, 0, 0, 0, time.UTC),
+		End:      time.Date(2021, time.March, 22, 9, 0, 0, 0, time.UTC),
+		TimeZone: "Europe/Berlin",
+	}
+
+	occurrences := event.Occurrences(event.Start, event.Start.AddDate(0, 1, 0))
+	require.Len(t, occurrences, 3)
+	for _, occurrence := range occurrences {
+		require.Equal(t, time.UTC, occurrence.Start.Location())
+		require.Equal(t, 9, occurrence.Start.In(berlin).Hour())
+		require.Equal(t, time.Hour, occurrence.Duration())
+	}
+	// После перехода на летнее время 28 марта повторение начинается на час раньше по UTC.
+	require.Equal(t, time.Date(2021, time.March, 29, 7, 0, 0, 0, time.UTC), occurrences[1].Start)
+
+	end, ok := event.SeriesEnd()
+	require.True(t, ok)
+	require.Equal(t, time.Date(2021, time.April, 5, 8, 0, 0, 0, time.UTC), end)
+
+	// Без часового пояса серия остаётся в 8:00 UTC.
+	event.TimeZone = ""
+	occurrences = event.Occurrences(event.Start, event.Start.AddDate(0, 1, 0))
+	require.Equal(t, time.Date(2021, time.March, 29, 8, 0, 0, 0, time.UTC), occurrences[1].Start)
+
+	event.TimeZone = "Local"
+	require.ErrorIs(t, event.Validate(), ErrInvalidEvent)
+	event.TimeZone = "Mars/Olympus"
+	require.ErrorIs(t, event.Validate(), ErrInvalidEvent)
+}
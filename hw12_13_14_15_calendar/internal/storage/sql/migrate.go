@@ -0,0 +1,15 @@
+package sqlstorage
+
+import (
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/migrations"
+	"github.com/pressly/goose/v3"
+)
+
+// Migrate выполняет команду goose (up, down, status и т.д.) над схемой хранилища.
+func (s *Storage) Migrate(command string, args ...string) error {
+	goose.SetBaseFS(migrations.FS)
+	if err := goose.SetDialect("postgres"); err != nil {
+		return err
+	}
+	return goose.Run(command, s.db, ".", args...)
+}
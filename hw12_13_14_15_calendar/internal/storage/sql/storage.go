@@ -1,20 +1,590 @@
 package sqlstorage
 
-import "context"
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
-type Storage struct { // TODO
+	"github.com/jackc/pgconn"
+	_ "github.com/jackc/pgx/v4/stdlib" // postgres driver
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+const uniqueViolation = "23505"
+
+const eventColumns = `id, title, start_at, end_at, description, user_id, notify_before, rrule, exdates, time_zone,
+	attendees, version, uid`
+
+// exdateFormat - формат исключённых повторений в колонке exdates.
+const exdateFormat = "20060102T150405Z"
+
+type Storage struct {
+	dsn string
+	db  *sql.DB
 }
 
-func New() *Storage {
-	return &Storage{}
+func New(dsn string) *Storage {
+	return &Storage{dsn: dsn}
 }
 
 func (s *Storage) Connect(ctx context.Context) error {
-	// TODO
+	db, err := sql.Open("pgx", s.dsn)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return fmt.Errorf("ping db: %w", err)
+	}
+	s.db = db
 	return nil
 }
 
 func (s *Storage) Close(ctx context.Context) error {
-	// TODO
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Create сохраняет новое событие. Если ID занят, в том числе событием в корзине,
+// или у владельца уже есть неудалённое событие с тем же UID, возвращается ErrEventExists.
+func (s *Storage) Create(ctx context.Context, event storage.Event) error {
+	if err := event.Validate(); err != nil {
+		return err
+	}
+	if event.UID == "" {
+		event.UID = event.ID
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := s.checkBusy(ctx, tx, event); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO events (`+eventColumns+`, series_end_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, 1, $12, $13)`,
+			event.ID, event.Title, event.Start, event.End, event.Description, event.UserID,
+			int64(event.NotifyBefore/time.Second), event.RRule, formatExDates(event.ExDates), event.TimeZone,
+			formatAttendees(event.Attendees), event.UID, seriesEnd(event),
+		)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return storage.ErrEventExists
+		}
+		return err
+	})
+}
+
+func (s *Storage) Update(ctx context.Context, id string, event storage.Event) error {
+	event.ID = id
+	if err := event.Validate(); err != nil {
+		return err
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		old, err := lockEvent(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if event.Version != 0 && event.Version != old.Version {
+			return storage.ErrVersionConflict
+		}
+		if err := s.checkBusy(ctx, tx, event); err != nil {
+			return err
+		}
+		event.Attendees = event.KeepStatuses(old)
+
+		// Перенесённое событие требует нового напоминания, поэтому notified_until сбрасывается,
+		// если изменилось время начала, уведомления, правило повторения или часовой пояс.
+		_, err = tx.ExecContext(ctx,
+			`UPDATE events
+			SET title = $2, start_at = $3, end_at = $4, description = $5, user_id = $6, notify_before = $7,
+				rrule = $8, exdates = $9, time_zone = $10, attendees = $11, series_end_at = $12, version = version + 1,
+				notified_until = CASE WHEN start_at = $3 AND notify_before = $7 AND rrule = $8 AND time_zone = $10
+					THEN notified_until END
+			WHERE id = $1`,
+			event.ID, event.Title, event.Start, event.End, event.Description, event.UserID,
+			int64(event.NotifyBefore/time.Second), event.RRule, formatExDates(event.ExDates), event.TimeZone,
+			formatAttendees(event.Attendees), seriesEnd(event),
+		)
+		return err
+	})
+}
+
+// SetAttendeeStatus сохраняет ответ участника userID на приглашение на событие id.
+// Если события нет или пользователь на него не приглашён, возвращается ErrNotFound.
+func (s *Storage) SetAttendeeStatus(
+	ctx context.Context, id, userID string, status storage.AttendeeStatus,
+) error {
+	if !status.Valid() {
+		return fmt.Errorf("%w: invalid attendee status %q", storage.ErrInvalidEvent, status)
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		event, err := lockEvent(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		attendees := event.Attendees
+		found := false
+		for i := range attendees {
+			if attendees[i].UserID == userID {
+				attendees[i].Status = status
+				found = true
+			}
+		}
+		if !found {
+			return storage.ErrNotFound
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE events SET attendees = $2, version = version + 1 WHERE id = $1`,
+			id, formatAttendees(attendees))
+		return err
+	})
+}
+
+// Delete переносит в корзину событие id версии version; нулевая версия удаляет любую.
+func (s *Storage) Delete(ctx context.Context, id string, version int64) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE events SET deleted_at = now(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND ($2::bigint = 0 OR version = $2)`, id, version)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	if version == 0 {
+		return storage.ErrNotFound
+	}
+
+	var exists bool
+	err = s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM events WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists)
+	switch {
+	case err != nil:
+		return err
+	case exists:
+		return storage.ErrVersionConflict
+	}
+	return storage.ErrNotFound
+}
+
+// Restore возвращает из корзины событие id, принадлежащее userID. Если события
+// нет в корзине или оно чужое, возвращается ErrNotFound, а если его UID занят
+// другим событием владельца - ErrEventExists.
+func (s *Storage) Restore(ctx context.Context, id, userID string) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx,
+			`SELECT `+eventColumns+` FROM events
+			WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL FOR UPDATE`,
+			id, userID,
+		)
+		event, err := scanEvent(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		// Пока событие было в корзине, его время могли занять.
+		if err := s.checkBusy(ctx, tx, event); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE events SET deleted_at = NULL, version = version + 1 WHERE id = $1`, id)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return storage.ErrEventExists
+		}
+		return err
+	})
+}
+
+// ListDeleted возвращает события пользователя из корзины, начиная с удалённых последними.
+func (s *Storage) ListDeleted(ctx context.Context, userID string) ([]storage.Event, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+eventColumns+`, deleted_at FROM events
+		WHERE user_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id COLLATE "C"`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]storage.Event, 0)
+	for rows.Next() {
+		var deletedAt time.Time
+		event, err := scanEvent(rows, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		event.DeletedAt = deletedAt.UTC()
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// ListOwned возвращает неудалённые события владельца userID по времени начала; серии
+// повторений не разворачиваются.
+func (s *Storage) ListOwned(ctx context.Context, userID string) ([]storage.Event, error) {
+	return s.query(ctx,
+		`SELECT `+eventColumns+` FROM events
+		WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY start_at, id COLLATE "C"`,
+		userID,
+	)
+}
+
+// DeleteTrashedBefore окончательно удаляет события, перенесённые в корзину раньше before,
+// и возвращает их количество.
+func (s *Storage) DeleteTrashedBefore(ctx context.Context, before time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM events WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *Storage) Get(ctx context.Context, id string) (storage.Event, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+eventColumns+` FROM events WHERE id = $1 AND deleted_at IS NULL`, id)
+	event, err := scanEvent(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	return event, err
+}
+
+// GetByUID возвращает событие владельца userID с UID uid; события в корзине не возвращаются.
+func (s *Storage) GetByUID(ctx context.Context, userID, uid string) (storage.Event, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT `+eventColumns+` FROM events WHERE user_id = $1 AND uid = $2 AND deleted_at IS NULL`, userID, uid)
+	event, err := scanEvent(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	return event, err
+}
+
+// ListDay возвращает страницу событий суток, в которые попадает date.
+func (s *Storage) ListDay(
+	ctx context.Context, userID string, date time.Time, opts storage.ListOptions,
+) ([]storage.Event, error) {
+	from, to := storage.DayPeriod(date)
+	return s.list(ctx, userID, from, to, opts)
+}
+
+// ListWeek возвращает страницу событий недели, начинающейся в день start.
+func (s *Storage) ListWeek(
+	ctx context.Context, userID string, start time.Time, opts storage.ListOptions,
+) ([]storage.Event, error) {
+	from, to := storage.WeekPeriod(start)
+	return s.list(ctx, userID, from, to, opts)
+}
+
+// ListMonth возвращает страницу событий месяца, начинающегося в день start.
+func (s *Storage) ListMonth(
+	ctx context.Context, userID string, start time.Time, opts storage.ListOptions,
+) ([]storage.Event, error) {
+	from, to := storage.MonthPeriod(start)
+	return s.list(ctx, userID, from, to, opts)
+}
+
+// ListRange возвращает события пользователя (для серий - повторения),
+// пересекающиеся с интервалом [from, to).
+func (s *Storage) ListRange(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error) {
+	return s.list(ctx, userID, from, to, storage.ListOptions{})
+}
+
+// ListNotifyDue возвращает ещё не начавшиеся события (для серий - ближайшие повторения),
+// для которых наступило время уведомления, но уведомление ещё не отправлялось.
+// Кандидаты выбираются запросом, повторения вычисляются на стороне приложения.
+func (s *Storage) ListNotifyDue(ctx context.Context, now time.Time) ([]storage.Event, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+eventColumns+`, notified_until FROM events
+		WHERE deleted_at IS NULL AND notify_before > 0 AND start_at - make_interval(secs => notify_before) <= $1
+			AND (series_end_at IS NULL OR series_end_at > $1)`,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]storage.Event, 0)
+	for rows.Next() {
+		var notified sql.NullTime
+		event, err := scanEvent(rows, &notified)
+		if err != nil {
+			return nil, err
+		}
+		if occurrence, ok := event.NextNotification(now, notified.Time); ok {
+			events = append(events, occurrence)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	storage.SortEvents(events)
+	return events, nil
+}
+
+// MarkNotified запоминает, что уведомление о повторении события, начинающемся в occurrence,
+// поставлено в очередь. Нулевое occurrence снимает отметку. Для событий в корзине, как и
+// для отсутствующих, возвращается ErrNotFound.
+func (s *Storage) MarkNotified(ctx context.Context, id string, occurrence time.Time) error {
+	var notified sql.NullTime
+	if !occurrence.IsZero() {
+		notified = sql.NullTime{Time: occurrence, Valid: true}
+	}
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE events SET notified_until = $2 WHERE id = $1 AND deleted_at IS NULL`, id, notified)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrNotFound
+	}
 	return nil
 }
+
+// DeleteEndedBefore удаляет события, закончившиеся раньше before, и возвращает их количество.
+// Серия удаляется, когда закончилось её последнее повторение.
+func (s *Storage) DeleteEndedBefore(ctx context.Context, before time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM events WHERE series_end_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// listWhere отбирает события и серии пользователя и события, на которые он приглашён,
+// которые могут пересекаться с интервалом [$2, $3) и проходят фильтр $4-$6.
+const listWhere = `(user_id = $1 OR attendees @> jsonb_build_array(jsonb_build_object('userId', $1::text)))
+	AND deleted_at IS NULL AND start_at < $3 AND (series_end_at IS NULL OR series_end_at > $2)
+	AND ($4 = '' OR position(lower($4) IN lower(title)) > 0)
+	AND ($5::boolean IS NULL OR (notify_before > 0) = $5)
+	AND ($6 = '' OR attendees @> jsonb_build_array(jsonb_build_object('userId', $6::text)))`
+
+// list возвращает страницу событий пользователя, пересекающихся с интервалом [from, to).
+// Одиночные события страницы выбираются запросом по курсору, а серии - целиком:
+// их повторения вычисляются на стороне приложения. ID сравниваются побайтно (COLLATE "C"),
+// как в storage.SortEvents и курсоре, иначе порядок зависел бы от правил сортировки базы.
+func (s *Storage) list(
+	ctx context.Context, userID string, from, to time.Time, opts storage.ListOptions,
+) ([]storage.Event, error) {
+	var (
+		after   sql.NullTime
+		limit   sql.NullInt64
+		reminds sql.NullBool
+	)
+	if !opts.After.IsZero() {
+		after = sql.NullTime{Time: opts.After.Start, Valid: true}
+	}
+	if opts.Limit > 0 {
+		limit = sql.NullInt64{Int64: int64(opts.Limit), Valid: true}
+	}
+	if opts.HasReminder != nil {
+		reminds = sql.NullBool{Bool: *opts.HasReminder, Valid: true}
+	}
+	candidates, err := s.query(ctx,
+		`(SELECT `+eventColumns+` FROM events
+		WHERE `+listWhere+` AND rrule = ''
+			AND ($7::timestamptz IS NULL OR (start_at, id COLLATE "C") > ($7, $8))
+		ORDER BY start_at, id COLLATE "C"
+		LIMIT $9)
+		UNION ALL
+		(SELECT `+eventColumns+` FROM events
+		WHERE `+listWhere+` AND rrule <> '')`,
+		userID, from, to, opts.Title, reminds, opts.Attendee, after, opts.After.ID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]storage.Event, 0, len(candidates))
+	for _, event := range candidates {
+		events = append(events, event.Occurrences(from, to)...)
+	}
+	storage.SortEvents(events)
+	return opts.Page(events), nil
+}
+
+func (s *Storage) query(ctx context.Context, query string, args ...interface{}) ([]storage.Event, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]storage.Event, 0)
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// checkBusy проверяет, что у владельца нет других событий в это время.
+// Конкурентные проверки одного владельца сериализуются advisory-блокировкой до конца транзакции.
+func (s *Storage) checkBusy(ctx context.Context, tx *sql.Tx, event storage.Event) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, event.UserID); err != nil {
+		return err
+	}
+
+	end, ok := event.SeriesEnd()
+	if !ok {
+		end = event.Start.Add(storage.OverlapHorizon)
+	}
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+eventColumns+` FROM events
+		WHERE user_id = $1 AND id <> $2 AND deleted_at IS NULL
+			AND start_at < $4 AND (series_end_at IS NULL OR series_end_at > $3)`,
+		event.UserID, event.ID, event.Start, end,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// Пересечения повторений серий проверяются на стороне приложения.
+	for rows.Next() {
+		other, err := scanEvent(rows)
+		if err != nil {
+			return err
+		}
+		if event.Overlaps(other) {
+			return storage.ErrDateBusy
+		}
+	}
+	return rows.Err()
+}
+
+func (s *Storage) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanEvent читает колонки eventColumns и, если переданы, дополнительные колонки в extra.
+func scanEvent(row scanner, extra ...interface{}) (storage.Event, error) {
+	var (
+		event        storage.Event
+		notifyBefore int64
+		exdates      string
+		attendees    []byte
+	)
+	dest := []interface{}{
+		&event.ID, &event.Title, &event.Start, &event.End, &event.Description, &event.UserID, &notifyBefore,
+		&event.RRule, &exdates, &event.TimeZone, &attendees, &event.Version, &event.UID,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return storage.Event{}, err
+	}
+	event.Start, event.End = event.Start.UTC(), event.End.UTC()
+	event.NotifyBefore = time.Duration(notifyBefore) * time.Second
+
+	var err error
+	if event.ExDates, err = parseExDates(exdates); err != nil {
+		return storage.Event{}, err
+	}
+	event.Attendees, err = parseAttendees(attendees)
+	return event, err
+}
+
+// lockEvent блокирует событие id до конца транзакции и возвращает его участников и версию.
+func lockEvent(ctx context.Context, tx *sql.Tx, id string) (storage.Event, error) {
+	var (
+		event     storage.Event
+		attendees []byte
+	)
+	err := tx.QueryRowContext(ctx,
+		`SELECT attendees, version FROM events WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id,
+	).Scan(&attendees, &event.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	if err != nil {
+		return storage.Event{}, err
+	}
+	event.Attendees, err = parseAttendees(attendees)
+	return event, err
+}
+
+// seriesEnd возвращает значение series_end_at: NULL для бесконечной серии.
+func seriesEnd(event storage.Event) sql.NullTime {
+	end, ok := event.SeriesEnd()
+	return sql.NullTime{Time: end, Valid: ok}
+}
+
+func formatExDates(exdates []time.Time) string {
+	values := make([]string, 0, len(exdates))
+	for _, exdate := range exdates {
+		values = append(values, exdate.UTC().Format(exdateFormat))
+	}
+	return strings.Join(values, ",")
+}
+
+func parseExDates(s string) ([]time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	values := strings.Split(s, ",")
+	exdates := make([]time.Time, 0, len(values))
+	for _, value := range values {
+		exdate, err := time.Parse(exdateFormat, value)
+		if err != nil {
+			return nil, fmt.Errorf("parse exdates: %w", err)
+		}
+		exdates = append(exdates, exdate)
+	}
+	return exdates, nil
+}
+
+func formatAttendees(attendees []storage.Attendee) string {
+	if len(attendees) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(attendees) // структура из строк всегда сериализуется
+	return string(data)
+}
+
+func parseAttendees(data []byte) ([]storage.Attendee, error) {
+	var attendees []storage.Attendee
+	if err := json.Unmarshal(data, &attendees); err != nil {
+		return nil, fmt.Errorf("parse attendees: %w", err)
+	}
+	if len(attendees) == 0 {
+		return nil, nil
+	}
+	return attendees, nil
+}
@@ -0,0 +1,383 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgconn"
+	"github.com/mluchkin/hw_otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+var columns = []string{
+	"id", "title", "start_at", "end_at", "description", "user_id", "notify_before", "rrule", "exdates", "time_zone",
+	"attendees", "version", "uid",
+}
+
+// lockQuery - блокировка события перед изменением.
+const lockQuery = `SELECT attendees, version FROM events WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`
+
+// listQuery - выборка одиночных событий страницы и серий пользователя.
+const listQuery = `\(SELECT (.+) FROM events\s+WHERE \(user_id = \$1 OR attendees @> (.+)\)\s+` +
+	`AND deleted_at IS NULL AND start_at < \$3 (.+) AND rrule = ''\s+` +
+	`AND \(\$7::timestamptz IS NULL OR \(start_at, id COLLATE "C"\) > \(\$7, \$8\)\)\s+` +
+	`ORDER BY start_at, id COLLATE "C"\s+LIMIT \$9\)\s+` +
+	`UNION ALL\s+\(SELECT (.+) AND rrule <> ''\)`
+
+func newMock(t *testing.T) (*Storage, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+
+	return &Storage{db: db}, mock
+}
+
+func newEvent() storage.Event {
+	start := time.Date(2021, time.September, 6, 10, 0, 0, 0, time.UTC)
+	return storage.Event{
+		ID:           "1",
+		UID:          "1",
+		Title:        "event",
+		Start:        start,
+		End:          start.Add(time.Hour),
+		UserID:       "user",
+		NotifyBefore: 15 * time.Minute,
+	}
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	event := newEvent()
+	// stored - то же событие, прочитанное из базы.
+	stored := event
+	stored.Version = 1
+
+	t.Run("create", func(t *testing.T) {
+		s, mock := newMock(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs("user").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT (.+) FROM events\s+WHERE user_id = \$1 AND id <> \$2`).
+			WithArgs("user", "1", event.Start, event.End).
+			WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectExec(`INSERT INTO events`).
+			WithArgs("1", "event", event.Start, event.End, "", "user", int64(900), "", "", "", "[]", "1", event.End).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		require.NoError(t, s.Create(ctx, event))
+	})
+
+	t.Run("create busy", func(t *testing.T) {
+		s, mock := newMock(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT (.+) FROM events`).WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("2", "other", event.Start.Add(30*time.Minute), event.End.Add(time.Hour), "", "user", int64(0),
+				"", "", "", "[]", int64(1), "2"))
+		mock.ExpectRollback()
+
+		require.ErrorIs(t, s.Create(ctx, event), storage.ErrDateBusy)
+	})
+
+	t.Run("create recurring between occurrences", func(t *testing.T) {
+		s, mock := newMock(t)
+		series := event
+		series.RRule = "FREQ=DAILY;COUNT=3"
+		series.ExDates = []time.Time{event.Start.AddDate(0, 0, 1)}
+		seriesEnd := event.End.AddDate(0, 0, 2)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+		// Кандидат попадает на исключённое повторение и не мешает созданию серии.
+		mock.ExpectQuery(`SELECT (.+) FROM events`).
+			WithArgs("user", "1", series.Start, seriesEnd).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("2", "other", series.ExDates[0], series.ExDates[0].Add(time.Hour), "", "user", int64(0),
+					"", "", "", "[]", int64(1), "2"))
+		mock.ExpectExec(`INSERT INTO events`).
+			WithArgs("1", "event", event.Start, event.End, "", "user", int64(900),
+				"FREQ=DAILY;COUNT=3", "20210907T100000Z", "", "[]", "1", seriesEnd).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		require.NoError(t, s.Create(ctx, series))
+	})
+
+	t.Run("create duplicate", func(t *testing.T) {
+		s, mock := newMock(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT (.+) FROM events`).WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectExec(`INSERT INTO events`).WillReturnError(&pgconn.PgError{Code: uniqueViolation})
+		mock.ExpectRollback()
+
+		require.ErrorIs(t, s.Create(ctx, event), storage.ErrEventExists)
+	})
+
+	t.Run("create invalid", func(t *testing.T) {
+		s, _ := newMock(t)
+		invalid := event
+		invalid.Title = ""
+
+		require.ErrorIs(t, s.Create(ctx, invalid), storage.ErrInvalidEvent)
+	})
+
+	t.Run("update not found", func(t *testing.T) {
+		s, mock := newMock(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).
+			WithArgs("1").WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		require.ErrorIs(t, s.Update(ctx, "1", event), storage.ErrNotFound)
+	})
+
+	t.Run("update keeps attendee statuses", func(t *testing.T) {
+		s, mock := newMock(t)
+		updated := event
+		updated.Attendees = []storage.Attendee{
+			{UserID: "bob", Status: storage.StatusPending},
+			{UserID: "carol", Status: storage.StatusPending},
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"attendees", "version"}).
+				AddRow(`[{"userId": "alice", "status": "accepted"}, {"userId": "bob", "status": "declined"}]`, int64(2)))
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT (.+) FROM events`).WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectExec(`UPDATE events`).
+			WithArgs("1", "event", event.Start, event.End, "", "user", int64(900), "", "", "",
+				`[{"userId":"bob","status":"declined"},{"userId":"carol","status":"pending"}]`, event.End).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		require.NoError(t, s.Update(ctx, "1", updated))
+	})
+
+	t.Run("set attendee status", func(t *testing.T) {
+		s, mock := newMock(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"attendees", "version"}).
+				AddRow(`[{"userId": "bob", "status": "pending"}]`, int64(1)))
+		mock.ExpectExec(`UPDATE events SET attendees = \$2, version = version \+ 1 WHERE id = \$1`).
+			WithArgs("1", `[{"userId":"bob","status":"tentative"}]`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		require.NoError(t, s.SetAttendeeStatus(ctx, "1", "bob", storage.StatusTentative))
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"attendees", "version"}).AddRow(`[]`, int64(1)))
+		mock.ExpectRollback()
+		require.ErrorIs(t, s.SetAttendeeStatus(ctx, "1", "bob", storage.StatusAccepted), storage.ErrNotFound)
+
+		require.ErrorIs(t, s.SetAttendeeStatus(ctx, "1", "bob", "maybe"), storage.ErrInvalidEvent)
+	})
+
+	t.Run("update version conflict", func(t *testing.T) {
+		s, mock := newMock(t)
+		stale := event
+		stale.Version = 1
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"attendees", "version"}).AddRow(`[]`, int64(2)))
+		mock.ExpectRollback()
+
+		require.ErrorIs(t, s.Update(ctx, "1", stale), storage.ErrVersionConflict)
+	})
+
+	t.Run("delete not found", func(t *testing.T) {
+		s, mock := newMock(t)
+		mock.ExpectExec(`UPDATE events SET deleted_at = now\(\)`).WithArgs("1", int64(0)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		require.ErrorIs(t, s.Delete(ctx, "1", 0), storage.ErrNotFound)
+	})
+
+	t.Run("delete version conflict", func(t *testing.T) {
+		s, mock := newMock(t)
+		mock.ExpectExec(`UPDATE events SET deleted_at = now\(\), version = version \+ 1\s+`+
+			`WHERE id = \$1 AND deleted_at IS NULL AND \(\$2::bigint = 0 OR version = \$2\)`).
+			WithArgs("1", int64(1)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT EXISTS`).WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		require.ErrorIs(t, s.Delete(ctx, "1", 1), storage.ErrVersionConflict)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		s, mock := newMock(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM events\s+WHERE id = \$1 AND user_id = \$2 AND deleted_at IS NOT NULL FOR UPDATE`).
+			WithArgs("1", "user").
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("1", "event", event.Start, event.End, "", "user", int64(900), "", "", "", "[]", int64(2), "1"))
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT (.+) FROM events\s+WHERE user_id = \$1 AND id <> \$2 AND deleted_at IS NULL`).
+			WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectExec(`UPDATE events SET deleted_at = NULL, version = version \+ 1 WHERE id = \$1`).WithArgs("1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		require.NoError(t, s.Restore(ctx, "1", "user"))
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM events`).WithArgs("1", "other").WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectRollback()
+		require.ErrorIs(t, s.Restore(ctx, "1", "other"), storage.ErrNotFound)
+	})
+
+	t.Run("list deleted", func(t *testing.T) {
+		s, mock := newMock(t)
+		deletedAt := event.Start.Add(-time.Hour)
+		mock.ExpectQuery(`SELECT (.+), deleted_at FROM events\s+WHERE user_id = \$1 AND deleted_at IS NOT NULL`).
+			WithArgs("user").
+			WillReturnRows(sqlmock.NewRows(append(columns, "deleted_at")).
+				AddRow("1", "event", event.Start, event.End, "", "user", int64(900), "", "", "", "[]", int64(2), "1", deletedAt))
+
+		events, err := s.ListDeleted(ctx, "user")
+		require.NoError(t, err)
+		deleted := event
+		deleted.Version, deleted.DeletedAt = 2, deletedAt
+		require.Equal(t, []storage.Event{deleted}, events)
+	})
+
+	t.Run("list owned", func(t *testing.T) {
+		s, mock := newMock(t)
+		mock.ExpectQuery(`SELECT (.+) FROM events\s+WHERE user_id = \$1 AND deleted_at IS NULL\s+ORDER BY start_at`).
+			WithArgs("user").
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("1", "event", event.Start, event.End, "", "user", int64(900), "", "", "", "[]", int64(1), "1"))
+
+		events, err := s.ListOwned(ctx, "user")
+		require.NoError(t, err)
+		require.Equal(t, []storage.Event{stored}, events)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		s, mock := newMock(t)
+		mock.ExpectQuery(`SELECT (.+) FROM events WHERE id = \$1`).WithArgs("1").
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("1", "event", event.Start, event.End, "", "user", int64(900), "", "", "", "[]", int64(1), "1"))
+
+		got, err := s.Get(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, stored, got)
+	})
+
+	t.Run("get by uid", func(t *testing.T) {
+		s, mock := newMock(t)
+		mock.ExpectQuery(`SELECT (.+) FROM events WHERE user_id = \$1 AND uid = \$2 AND deleted_at IS NULL`).
+			WithArgs("user", "1").
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("1", "event", event.Start, event.End, "", "user", int64(900), "", "", "", "[]", int64(1), "1"))
+		mock.ExpectQuery(`SELECT (.+) FROM events WHERE user_id = \$1 AND uid = \$2`).WithArgs("other", "1").
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		got, err := s.GetByUID(ctx, "user", "1")
+		require.NoError(t, err)
+		require.Equal(t, stored, got)
+		_, err = s.GetByUID(ctx, "other", "1")
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("get not found", func(t *testing.T) {
+		s, mock := newMock(t)
+		mock.ExpectQuery(`SELECT (.+) FROM events WHERE id = \$1`).WithArgs("2").
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		_, err := s.Get(ctx, "2")
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("list week", func(t *testing.T) {
+		s, mock := newMock(t)
+		from := time.Date(2021, time.September, 6, 0, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(listQuery).
+			WithArgs("user", from, from.AddDate(0, 0, 7), "", nil, "", nil, "", nil).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("1", "event", event.Start, event.End, "", "user", int64(900), "", "", "", "[]", int64(1), "1").
+				AddRow("2", "gym", event.Start.AddDate(0, 0, -7), event.End.AddDate(0, 0, -7), "", "coach", int64(0),
+					"FREQ=WEEKLY;BYDAY=MO,FR", "20210910T100000Z", "", `[{"userId": "user", "status": "accepted"}]`,
+					int64(3), "2"))
+
+		gym := storage.Event{
+			ID: "2", UID: "2", Title: "gym", UserID: "coach", RRule: "FREQ=WEEKLY;BYDAY=MO,FR",
+			ExDates:   []time.Time{event.Start.AddDate(0, 0, 4)},
+			Attendees: []storage.Attendee{{UserID: "user", Status: storage.StatusAccepted}},
+			Version:   3,
+		}
+		occurrence := gym
+		occurrence.Start, occurrence.End = event.Start, event.End
+
+		events, err := s.ListWeek(ctx, "user", from.Add(12*time.Hour), storage.ListOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []storage.Event{stored, occurrence}, events)
+	})
+
+	t.Run("list page", func(t *testing.T) {
+		s, mock := newMock(t)
+		from := time.Date(2021, time.September, 6, 0, 0, 0, 0, time.UTC)
+		after := storage.Cursor{Start: event.Start, ID: "0"}
+		reminds := true
+		mock.ExpectQuery(listQuery).
+			WithArgs("user", from, from.AddDate(0, 0, 7), "EV", true, "", after.Start, "0", int64(1)).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("1", "event", event.Start, event.End, "", "user", int64(900), "", "", "", "[]", int64(1), "1").
+				AddRow("2", "evening", event.Start, event.End, "", "user", int64(900), "", "", "", "[]", int64(1), "2"))
+
+		events, err := s.ListWeek(ctx, "user", from, storage.ListOptions{
+			ListFilter: storage.ListFilter{Title: "EV", HasReminder: &reminds},
+			After:      after,
+			Limit:      1,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []storage.Event{stored}, events)
+	})
+
+	t.Run("list notify due", func(t *testing.T) {
+		s, mock := newMock(t)
+		now := event.Start.Add(-10 * time.Minute)
+		mock.ExpectQuery(`SELECT (.+), notified_until FROM events\s+WHERE deleted_at IS NULL AND notify_before > 0`).
+			WithArgs(now).
+			WillReturnRows(sqlmock.NewRows(append(columns, "notified_until")).
+				AddRow("1", "event", event.Start, event.End, "", "user", int64(900), "", "", "", "[]", int64(1), "1", nil).
+				AddRow("2", "notified", event.Start, event.End, "", "user", int64(900), "", "", "", "[]", int64(1), "2",
+					event.Start))
+
+		events, err := s.ListNotifyDue(ctx, now)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Event{stored}, events)
+	})
+
+	t.Run("mark notified not found", func(t *testing.T) {
+		s, mock := newMock(t)
+		mock.ExpectExec(`UPDATE events SET notified_until = \$2 WHERE id = \$1 AND deleted_at IS NULL`).
+			WithArgs("1", event.Start).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		require.ErrorIs(t, s.MarkNotified(ctx, "1", event.Start), storage.ErrNotFound)
+	})
+
+	t.Run("delete ended before", func(t *testing.T) {
+		s, mock := newMock(t)
+		before := event.Start.AddDate(-1, 0, 0)
+		mock.ExpectExec(`DELETE FROM events WHERE series_end_at < \$1`).
+			WithArgs(before).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+
+		deleted, err := s.DeleteEndedBefore(ctx, before)
+		require.NoError(t, err)
+		require.Equal(t, 3, deleted)
+	})
+}
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE events (
+    id            TEXT PRIMARY KEY,
+    title         TEXT        NOT NULL,
+    start_at      TIMESTAMPTZ NOT NULL,
+    end_at        TIMESTAMPTZ NOT NULL,
+    description   TEXT        NOT NULL DEFAULT '',
+    user_id       TEXT        NOT NULL,
+    -- за сколько секунд до начала события отправлять уведомление
+    notify_before BIGINT      NOT NULL DEFAULT 0,
+    CHECK (end_at > start_at),
+    CHECK (notify_before >= 0)
+);
+
+CREATE INDEX events_user_id_start_at_idx ON events (user_id, start_at);
+CREATE INDEX events_start_at_idx ON events (start_at);
+
+-- +goose Down
+DROP TABLE events;
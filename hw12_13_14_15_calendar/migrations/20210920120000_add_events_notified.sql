@@ -0,0 +1,9 @@
+-- +goose Up
+-- notified - уведомление о событии уже поставлено в очередь планировщиком
+ALTER TABLE events ADD COLUMN notified BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX events_notify_idx ON events (start_at) WHERE notify_before > 0 AND NOT notified;
+
+-- +goose Down
+DROP INDEX events_notify_idx;
+ALTER TABLE events DROP COLUMN notified;
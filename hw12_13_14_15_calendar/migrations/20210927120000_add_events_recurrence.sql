@@ -0,0 +1,36 @@
+-- +goose Up
+-- rrule - правило повторения RFC 5545, пустое для одиночных событий;
+-- exdates - исключённые повторения через запятую в формате 20060102T150405Z;
+-- series_end_at - окончание последнего повторения, NULL для бесконечных серий;
+-- notified_until - начало последнего повторения, о котором отправлено уведомление.
+ALTER TABLE events
+    ADD COLUMN rrule          TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN exdates        TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN series_end_at  TIMESTAMPTZ NULL,
+    ADD COLUMN notified_until TIMESTAMPTZ NULL;
+
+UPDATE events SET series_end_at = end_at;
+UPDATE events SET notified_until = start_at WHERE notified;
+
+DROP INDEX events_notify_idx;
+ALTER TABLE events DROP COLUMN notified;
+
+CREATE INDEX events_series_end_at_idx ON events (series_end_at);
+CREATE INDEX events_notify_idx ON events (start_at) WHERE notify_before > 0;
+
+-- +goose Down
+DROP INDEX events_notify_idx;
+DROP INDEX events_series_end_at_idx;
+
+ALTER TABLE events ADD COLUMN notified BOOLEAN NOT NULL DEFAULT false;
+UPDATE events SET notified = notified_until IS NOT NULL;
+-- серии повторений в прежней схеме не представимы
+DELETE FROM events WHERE rrule <> '';
+
+ALTER TABLE events
+    DROP COLUMN rrule,
+    DROP COLUMN exdates,
+    DROP COLUMN series_end_at,
+    DROP COLUMN notified_until;
+
+CREATE INDEX events_notify_idx ON events (start_at) WHERE notify_before > 0 AND NOT notified;
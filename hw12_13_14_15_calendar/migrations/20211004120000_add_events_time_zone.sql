@@ -0,0 +1,6 @@
+-- +goose Up
+-- time_zone - IANA-имя часового пояса, в котором создано событие, пустое для UTC.
+ALTER TABLE events ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE events DROP COLUMN time_zone;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- attendees - приглашённые пользователи: [{"userId": "...", "status": "pending"}, ...].
+ALTER TABLE events ADD COLUMN attendees JSONB NOT NULL DEFAULT '[]';
+
+CREATE INDEX events_attendees_idx ON events USING GIN (attendees jsonb_path_ops);
+
+-- +goose Down
+DROP INDEX events_attendees_idx;
+
+ALTER TABLE events DROP COLUMN attendees;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- version - номер версии события для оптимистичной блокировки, растёт при каждом изменении.
+ALTER TABLE events ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE events DROP COLUMN version;
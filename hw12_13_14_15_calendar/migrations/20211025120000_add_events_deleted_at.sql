@@ -0,0 +1,10 @@
+-- +goose Up
+-- deleted_at - момент переноса события в корзину, NULL у действующих событий.
+ALTER TABLE events ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX events_deleted_at_idx ON events (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX events_deleted_at_idx;
+
+ALTER TABLE events DROP COLUMN deleted_at;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- uid - UID события в iCalendar. Он уникален только среди событий владельца: одно и то же
+-- приглашение из Outlook или Google у разных пользователей - разные события. Удалённое
+-- событие UID не занимает, чтобы клиент CalDAV мог создать ресурс с тем же именем заново.
+ALTER TABLE events ADD COLUMN uid TEXT;
+UPDATE events SET uid = id;
+ALTER TABLE events ALTER COLUMN uid SET NOT NULL;
+
+CREATE UNIQUE INDEX events_user_id_uid_idx ON events (user_id, uid) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX events_user_id_uid_idx;
+
+ALTER TABLE events DROP COLUMN uid;
@@ -0,0 +1,8 @@
+package migrations
+
+import "embed"
+
+// FS содержит SQL-миграции схемы хранилища событий.
+//
+//go:embed *.sql
+var FS embed.FS